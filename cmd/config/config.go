@@ -4,12 +4,17 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	ServerConf    ServerConf
 	EthClientConf EthClientConf
+	StorageConf   StorageConf
+	ABIConf       ABIConf
+	StreamConf    StreamConf
+	WebhookConf   WebhookConf
 }
 
 type ServerConf struct {
@@ -17,15 +22,63 @@ type ServerConf struct {
 	ServerPort   string        `envconfig:"SERVER_PORT" default:"8000"`
 	ReadTimeout  time.Duration `envconfig:"READ_TIMEOUT" default:"5"`
 	WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"5"`
+	AdminToken   string        `envconfig:"ADMIN_TOKEN"` // bearer token of the admin endpoints, which are closed without it
 }
 
 type EthClientConf struct {
-	EthereumHttpURL               string `envconfig:"HTTP_ETH_URL"`
-	EthereumWSSURL                string `envconfig:"WSS_ETH_URL"`
-	NumberOfRecentBlocks          int    `envconfig:"NUMBER_OF_RECENT_BLOCKS" default:"50"`
-	NumberOfBlockProcessorWorkers int    `envconfig:"NUMBER_OF_BLOCK_PROCESSOR_WORKERS" default:"7"`
+	EthereumHttpURLs              []string `envconfig:"HTTP_ETH_URL"` // comma separated, to fail over between the endpoints
+	EthereumWSSURLs               []string `envconfig:"WSS_ETH_URL"`
+	NumberOfRecentBlocks          int      `envconfig:"NUMBER_OF_RECENT_BLOCKS" default:"50"`
+	NumberOfBlockProcessorWorkers int      `envconfig:"NUMBER_OF_BLOCK_PROCESSOR_WORKERS" default:"7"`
+	ReceiptsBatchSize             int      `envconfig:"RECEIPTS_BATCH_SIZE" default:"100"`
+	RPCRateLimit                  int      `envconfig:"RPC_RATE_LIMIT" default:"0"` // requests per second, zero disables the limiter
+	RPCRateBurst                  int      `envconfig:"RPC_RATE_BURST" default:"100"`
+
+	RetryMaxAttempts int           `envconfig:"RETRY_MAX_ATTEMPTS" default:"5"`
+	RetryBaseBackoff time.Duration `envconfig:"RETRY_BASE_BACKOFF" default:"2"`
+
+	ReconnectMinBackoff time.Duration `envconfig:"WSS_RECONNECT_MIN_BACKOFF" default:"1"`
+	ReconnectMaxBackoff time.Duration `envconfig:"WSS_RECONNECT_MAX_BACKOFF" default:"60"`
+
+	SyncMode        string        `envconfig:"SYNC_MODE" default:"auto"`
+	PollingInterval time.Duration `envconfig:"POLLING_INTERVAL" default:"12"`
+
+	BlockTagsRefreshInterval time.Duration `envconfig:"BLOCK_TAGS_REFRESH_INTERVAL" default:"60"` // of the safe and finalized blocks
+}
+
+type StorageConf struct {
+	Backend string `envconfig:"STORAGE_BACKEND" default:"memory"`
+	Path    string `envconfig:"STORAGE_PATH" default:"ethereum-tracker.db"`
+}
+
+type ABIConf struct {
+	Directory    string `envconfig:"ABI_DIRECTORY"`                    // json abis named by the contract address, empty keeps the uploaded abis only in memory
+	MaxContracts int    `envconfig:"ABI_MAX_CONTRACTS" default:"1000"` // the uploads of new contracts are rejected above it, zero disables the cap
 }
 
+type StreamConf struct {
+	SubscriptionBufferSize int `envconfig:"SUBSCRIPTION_BUFFER_SIZE" default:"1024"` // items a live subscriber may lag behind before it is dropped
+}
+
+type WebhookConf struct {
+	Timeout             time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10"`
+	RetryBaseBackoff    time.Duration `envconfig:"WEBHOOK_RETRY_BASE_BACKOFF" default:"1"`
+	RetryMaxBackoff     time.Duration `envconfig:"WEBHOOK_RETRY_MAX_BACKOFF" default:"300"`
+	MaxFailures         int           `envconfig:"WEBHOOK_MAX_FAILURES" default:"10"`             // consecutive failed attempts before the webhook is disabled
+	AllowPrivateTargets bool          `envconfig:"WEBHOOK_ALLOW_PRIVATE_TARGETS" default:"false"` // lets the webhooks post to the loopback, private and link-local addresses
+}
+
+const (
+	SyncModeAuto         = "auto" // subscription if the wss url is reachable, otherwise polling
+	SyncModeSubscription = "subscription"
+	SyncModePolling      = "polling"
+)
+
+const (
+	StorageBackendMemory = "memory"
+	StorageBackendBolt   = "bolt"
+)
+
 func LoadConfig(logger *log.Logger) *Config {
 	return &Config{
 		ServerConf: ServerConf{
@@ -33,12 +86,41 @@ func LoadConfig(logger *log.Logger) *Config {
 			ServerPort:   getEnv("SERVER_PORT", "8000"),
 			ReadTimeout:  time.Duration(getEnvAsInt("READ_TIMEOUT", 5)) * time.Second,
 			WriteTimeout: time.Duration(getEnvAsInt("WRITE_TIMEOUT", 5)) * time.Second,
+			AdminToken:   getEnv("ADMIN_TOKEN", ""),
 		},
 		EthClientConf: EthClientConf{
-			EthereumHttpURL:               getEnv("HTTP_ETH_URL", ""),
-			EthereumWSSURL:                getEnv("WSS_ETH_URL", ""),
+			EthereumHttpURLs:              getEnvAsSlice("HTTP_ETH_URL"),
+			EthereumWSSURLs:               getEnvAsSlice("WSS_ETH_URL"),
 			NumberOfRecentBlocks:          getEnvAsInt("NUMBER_OF_RECENT_BLOCKS", 50),
 			NumberOfBlockProcessorWorkers: getEnvAsInt("NUMBER_OF_BLOCK_PROCESSOR_WORKERS", 7),
+			ReceiptsBatchSize:             getEnvAsInt("RECEIPTS_BATCH_SIZE", 100),
+			RPCRateLimit:                  getEnvAsInt("RPC_RATE_LIMIT", 0),
+			RPCRateBurst:                  getEnvAsInt("RPC_RATE_BURST", 100),
+			RetryMaxAttempts:              getEnvAsInt("RETRY_MAX_ATTEMPTS", 5),
+			RetryBaseBackoff:              time.Duration(getEnvAsInt("RETRY_BASE_BACKOFF", 2)) * time.Second,
+			ReconnectMinBackoff:           time.Duration(getEnvAsInt("WSS_RECONNECT_MIN_BACKOFF", 1)) * time.Second,
+			ReconnectMaxBackoff:           time.Duration(getEnvAsInt("WSS_RECONNECT_MAX_BACKOFF", 60)) * time.Second,
+			SyncMode:                      getEnv("SYNC_MODE", SyncModeAuto),
+			PollingInterval:               time.Duration(getEnvAsInt("POLLING_INTERVAL", 12)) * time.Second,
+			BlockTagsRefreshInterval:      time.Duration(getEnvAsInt("BLOCK_TAGS_REFRESH_INTERVAL", 60)) * time.Second,
+		},
+		StorageConf: StorageConf{
+			Backend: getEnv("STORAGE_BACKEND", StorageBackendMemory),
+			Path:    getEnv("STORAGE_PATH", "ethereum-tracker.db"),
+		},
+		ABIConf: ABIConf{
+			Directory:    getEnv("ABI_DIRECTORY", ""),
+			MaxContracts: getEnvAsInt("ABI_MAX_CONTRACTS", 1000),
+		},
+		StreamConf: StreamConf{
+			SubscriptionBufferSize: getEnvAsInt("SUBSCRIPTION_BUFFER_SIZE", 1024),
+		},
+		WebhookConf: WebhookConf{
+			Timeout:             time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT", 10)) * time.Second,
+			RetryBaseBackoff:    time.Duration(getEnvAsInt("WEBHOOK_RETRY_BASE_BACKOFF", 1)) * time.Second,
+			RetryMaxBackoff:     time.Duration(getEnvAsInt("WEBHOOK_RETRY_MAX_BACKOFF", 300)) * time.Second,
+			MaxFailures:         getEnvAsInt("WEBHOOK_MAX_FAILURES", 10),
+			AllowPrivateTargets: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
 		},
 	}
 }
@@ -59,3 +141,23 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// Helper function to get a boolean environment variable with a fallback
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// Helper function to get a comma separated environment variable as a slice, skipping the empty values
+func getEnvAsSlice(key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
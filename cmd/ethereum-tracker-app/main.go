@@ -5,8 +5,13 @@ import (
 	"ethereum-tracker-app/cmd/config"
 	routers "ethereum-tracker-app/internal/http"
 	"ethereum-tracker-app/internal/http/handlers"
+	"ethereum-tracker-app/internal/services/abiregistry"
 	"ethereum-tracker-app/internal/services/blockprocessor"
 	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/internal/services/rpcfacade"
+	"ethereum-tracker-app/internal/services/webhook"
+	"ethereum-tracker-app/internal/storage/boltdb"
 	"ethereum-tracker-app/internal/storage/inmemorydb"
 	"fmt"
 	"log"
@@ -26,20 +31,34 @@ func main() {
 	systemConfig := config.LoadConfig(logger)
 
 	//todo: variadic function to pass ...options to constructors. passing option functions instead of one-by-one entities
-	storage := inmemorydb.NewInmemortDBService(*systemConfig, logger)
+	storage, storageErr := newStorage(*systemConfig, logger)
+	if storageErr != nil {
+		logger.Fatal(errors.Wrap(storageErr, "cannot setup the storage"))
+	}
 	blockprocessService := blocksearch.NewServie(*systemConfig, logger, storage)
-	handler := handlers.NewHandler(blockprocessService)
-	router := routers.SetupRouters(handler)
-	ethClient, ethClientErr := blockprocessor.NewEthClient(ctx, *systemConfig, logger, storage)
+	feed := chainfeed.NewService(*systemConfig, logger)
+	ethClient, ethClientErr := blockprocessor.NewEthClient(ctx, *systemConfig, logger, storage, feed)
 	if ethClientErr != nil {
 		logger.Fatal(errors.Wrap(ethClientErr, "cannot stablish Ethereum client"))
 	}
+	abiRegistry, abiRegistryErr := abiregistry.NewRegistry(*systemConfig, logger)
+	if abiRegistryErr != nil {
+		logger.Fatal(errors.Wrap(abiRegistryErr, "cannot setup the abi registry"))
+	}
+	rpcFacade := rpcfacade.NewService(*systemConfig, logger, storage)
+	webhooks, webhooksErr := webhook.NewService(*systemConfig, logger, storage, feed, abiRegistry)
+	if webhooksErr != nil {
+		logger.Fatal(errors.Wrap(webhooksErr, "cannot setup the webhooks"))
+	}
+	handler := handlers.NewHandler(blockprocessService, ethClient, abiRegistry, rpcFacade, feed, webhooks, systemConfig.ServerConf.AdminToken)
+	router := routers.SetupRouters(handler)
 
 	appService := &Service{
 		Config:            systemConfig,
 		Logger:            logger,
 		EthClient:         ethClient,
 		BlockProcessSvc:   blockprocessService,
+		Webhooks:          webhooks,
 		Router:            router,
 		InMemoryDBService: storage,
 		Server: &http.Server{
@@ -54,3 +73,15 @@ func main() {
 		appService.Logger.Fatalf("Failed to run the service: %v", err)
 	}
 }
+
+// newStorage creates the datastore of the configured storage backend
+func newStorage(systemConfig config.Config, logger *log.Logger) (inmemorydb.Service, error) {
+	switch systemConfig.StorageConf.Backend {
+	case config.StorageBackendMemory:
+		return inmemorydb.NewInmemortDBService(systemConfig, logger), nil
+	case config.StorageBackendBolt:
+		return boltdb.NewBoltDBService(systemConfig, logger)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", systemConfig.StorageConf.Backend)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"ethereum-tracker-app/cmd/config"
 	"ethereum-tracker-app/internal/services/blockprocessor"
 	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/internal/services/webhook"
 	"ethereum-tracker-app/internal/storage/inmemorydb"
 	"log"
 	"net/http"
@@ -23,6 +24,7 @@ type Service struct {
 	Logger            *log.Logger
 	EthClient         blockprocessor.Service
 	BlockProcessSvc   blocksearch.Service
+	Webhooks          webhook.Service
 	InMemoryDBService inmemorydb.Service
 	Router            http.Handler
 	Server            *http.Server
@@ -33,7 +35,7 @@ type Service struct {
 //   - starts all gouroutines
 //   - handles graceful shutdown
 func (s *Service) run(ctx context.Context) error {
-	blockTxChan := make(chan types.Transactions, s.Config.EthClientConf.NumberOfRecentBlocks)
+	blockChan := make(chan *types.Block, s.Config.EthClientConf.NumberOfRecentBlocks)
 	wg := &sync.WaitGroup{}
 
 	// Create a cancellable context
@@ -61,7 +63,7 @@ func (s *Service) run(ctx context.Context) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			s.EthClient.WokerTransactionProcessor(ctx, blockTxChan, wg)
+			s.EthClient.WokerTransactionProcessor(ctx, blockChan, wg)
 		}()
 	}
 
@@ -69,12 +71,24 @@ func (s *Service) run(ctx context.Context) error {
 	wg2.Add(1)
 	go func() {
 		defer wg2.Done()
-		if err := s.EthClient.SubscribeToNewGeneratedBlocks(ctx); err != nil {
-			s.Logger.Fatalf("Failed to subscribe to new generated blocks: %v", err)
+		if err := s.EthClient.SyncNewGeneratedBlocks(ctx); err != nil {
+			s.Logger.Fatalf("Failed to synchronize new generated blocks: %v", err)
 		}
 	}()
 
-	if err := s.EthClient.FetchAndStoreRecentBlocks(ctx, blockTxChan); err != nil {
+	wg2.Add(1)
+	go func() {
+		defer wg2.Done()
+		s.EthClient.ProcessRetryQueue(ctx)
+	}()
+
+	wg2.Add(1)
+	go func() {
+		defer wg2.Done()
+		s.Webhooks.Run(ctx)
+	}()
+
+	if err := s.EthClient.FetchAndStoreRecentBlocks(ctx, blockChan); err != nil {
 		s.Logger.Printf("Failed to fetch and store recent blocks: %v", err)
 		return errors.Wrap(err, "failed to fetch and store recent blocks")
 	}
@@ -94,5 +108,9 @@ func (s *Service) run(ctx context.Context) error {
 
 	wg2.Wait()
 
+	if err := s.InMemoryDBService.Close(); err != nil {
+		s.Logger.Printf("Error closing the storage: %v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/models"
+	"io"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+const maxABISize = 1 << 20
+
+// Register ABI API endpoint
+// @Summary Register the ABI of a contract
+// @Description Upload the json ABI of a contract, so its events are returned with their name and decoded arguments. The uploads of new contracts are rejected once ABI_MAX_CONTRACTS contracts are registered
+// @Tags ABIs
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param address path string true "address of the contract"
+// @Param abi body string true "json ABI of the contract"
+// @Success 201 {object} models.ABIResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /abis/{address} [put]
+
+// RegisterABI Registers the ABI of a contract, replacing the previous one
+func (h *handler) RegisterABI(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: address is not a valid hex address")
+		return
+	}
+
+	abiJSON, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxABISize))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: abi cannot be read")
+		return
+	}
+
+	if err := h.abiRegistry.Register(r.Context(), common.HexToAddress(address), abiJSON); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, models.ABIResponse{
+		Status:  models.StatusCreated,
+		Address: common.HexToAddress(address).Hex(),
+	})
+}
+
+// Get ABI API endpoint
+// @Summary Get the ABI of a contract
+// @Description Retrieve the registered json ABI of a contract
+// @Tags ABIs
+// @Produce json
+// @Param address path string true "address of the contract"
+// @Success 200 {object} models.ABIResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /abis/{address} [get]
+
+// GetABI Gets the registered ABI of a contract
+func (h *handler) GetABI(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: address is not a valid hex address")
+		return
+	}
+
+	abiJSON, err := h.abiRegistry.GetABI(r.Context(), common.HexToAddress(address))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.ABIResponse{
+		Status:  models.StatusSuccess,
+		Address: common.HexToAddress(address).Hex(),
+		ABI:     abiJSON,
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/models"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+// Address transactions API endpoint
+// @Summary Get transactions by address
+// @Description Retrieve the transactions sent or received by an address, including the contract creations received by the created contract
+// @Tags Transactions
+// @Produce json
+// @Param address path string true "an address in the blockchain"
+// @Param direction query string false "in, out or any (default)"
+// @Param limit query int false "maximum number of transactions in the page, 100 by default and at most 1000"
+// @Param cursor query string false "the next cursor of the previous page"
+// @Success 200 {object} models.AddressTransactionsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /addresses/{address}/transactions [get]
+
+// GetTransactionsByAddress Gets the transactions of a specific address
+func (h *handler) GetTransactionsByAddress(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: address is not a valid hex address")
+		return
+	}
+
+	filter := blocksearch.TransactionFilter{Direction: models.TransferDirection(r.URL.Query().Get("direction"))}
+	switch filter.Direction {
+	case "", models.TransferDirectionIn, models.TransferDirectionOut, models.TransferDirectionAny:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: direction must be one of in, out and any")
+		return
+	}
+
+	limit, err := parseLimit(r, blocksearch.DefaultPageLimit, blocksearch.MaxPageLimit)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	// the transactions are indexed by the checksummed address
+	page := blocksearch.Page{Limit: limit, Cursor: r.URL.Query().Get("cursor")}
+	transactions, err := h.blockProcessService.GetTransactionsByAddress(r.Context(), common.HexToAddress(address).Hex(), filter, page)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.AddressTransactionsResponse{
+		Status:       models.StatusSuccess,
+		Address:      address,
+		Transactions: transactions.Transactions,
+		Next:         transactions.Next,
+	})
+}
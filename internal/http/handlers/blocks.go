@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const hashHexLength = 66 // 0x prefix and 32 bytes
+
+// Blocks API endpoint
+// @Summary Get a block
+// @Description Retrieve the summary of an indexed block by its number, its hash or one of the latest, safe and finalized tags
+// @Tags Blocks
+// @Produce json
+// @Param numberOrHash path string true "number, hash or tag of the block"
+// @Success 200 {object} models.BlockResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /blocks/{numberOrHash} [get]
+
+// GetBlock Gets an indexed block, which is not found when it is outside the window of the recent blocks
+func (h *handler) GetBlock(w http.ResponseWriter, r *http.Request) {
+	numberOrHash := mux.Vars(r)["numberOrHash"]
+
+	var (
+		block models.Block
+		err   error
+	)
+	if len(numberOrHash) == hashHexLength {
+		hash, parseErr := parseHashValue("block hash", numberOrHash)
+		if parseErr != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+parseErr.Error())
+			return
+		}
+		block, err = h.blockProcessService.GetBlockByHash(r.Context(), *hash)
+	} else {
+		ref, parseErr := parseBlockRefValue("block", numberOrHash)
+		if parseErr != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+parseErr.Error())
+			return
+		}
+		block, err = h.blockProcessService.GetBlock(r.Context(), *ref)
+	}
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.BlockResponse{
+		Status: models.StatusSuccess,
+		Block:  block,
+	})
+}
+
+// Block transactions API endpoint
+// @Summary Get the transactions of a block
+// @Description Retrieve the hashes of the transactions of an indexed block in their order, page by page
+// @Tags Blocks
+// @Produce json
+// @Param number path string true "number or tag of the block"
+// @Param limit query int false "maximum number of transaction hashes in the page, 100 by default and at most 1000"
+// @Param cursor query string false "the next cursor of the previous page"
+// @Success 200 {object} models.BlockTransactionsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /blocks/{number}/transactions [get]
+
+// GetBlockTransactions Gets the transaction hashes of an indexed block
+func (h *handler) GetBlockTransactions(w http.ResponseWriter, r *http.Request) {
+	ref, err := parseBlockRefValue("block", mux.Vars(r)["number"])
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	limit, err := parseLimit(r, blocksearch.DefaultPageLimit, blocksearch.MaxPageLimit)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	page := blocksearch.Page{Limit: limit, Cursor: r.URL.Query().Get("cursor")}
+	txs, err := h.blockProcessService.GetBlockTransactions(r.Context(), *ref, page)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.BlockTransactionsResponse{
+		Status:            models.StatusSuccess,
+		BlockNumber:       txs.BlockNumber,
+		BlockHash:         txs.BlockHash.Hex(),
+		TransactionHashes: txs.TxHashes,
+		Next:              txs.Next,
+	})
+}
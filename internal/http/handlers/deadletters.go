@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Dead letters API endpoint
+// @Summary Get dead letters
+// @Description Retrieve the blocks which could not be fetched, or whose transactions' receipts could not be retrieved, after all the retries, so their events are missing. The dead letters are kept across restarts by the bolt storage backend
+// @Tags DeadLetters
+// @Produce json
+// @Success 200 {object} models.DeadLettersResponse
+// @Router /deadletters [get]
+
+// GetDeadLetters Gets the blocks whose events are missing after all the retries
+func (h *handler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, models.DeadLettersResponse{
+		Status:      models.StatusSuccess,
+		DeadLetters: h.ethClient.GetDeadLetters(r.Context()),
+	})
+}
+
+// Reprocess dead letters API endpoint
+// @Summary Reprocess dead letters
+// @Description Retry the dead letters of a block again, fetching the block or the receipts of its failed transactions. A repeated request for a block which is being retried is ignored
+// @Tags DeadLetters
+// @Security BearerAuth
+// @Produce json
+// @Param blockNumber path int true "number of the block"
+// @Success 202 {object} models.DeadLettersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /deadletters/{blockNumber}/reprocess [post]
+
+// ReprocessDeadLetters Triggers the retries of the dead letters of a block
+func (h *handler) ReprocessDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	blockNumber, err := strconv.ParseUint(vars["blockNumber"], 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: block number is not a valid number")
+		return
+	}
+
+	if err := h.ethClient.ReprocessDeadLetters(r.Context(), blockNumber); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, models.DeadLettersResponse{
+		Status:      models.StatusAccepted,
+		DeadLetters: h.ethClient.GetDeadLetters(r.Context()),
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"ethereum-tracker-app/internal/services/blocksearch"
 	"ethereum-tracker-app/models"
 	"net/http"
 
@@ -15,7 +16,17 @@ import (
 // @Accept json
 // @Produce json
 // @Param address path string true "an address in the blockchain"
-// @Success 200 {array} types.Log
+// @Param role query string false "emitter (default) for the events emitted by the address, participant for the events referring to the address in their indexed topics, or any"
+// @Param fromBlock query string false "first block of the range, a number or one of latest, safe and finalized"
+// @Param toBlock query string false "last block of the range, a number or one of latest, safe and finalized"
+// @Param blockHash query string false "hash of a single block, instead of fromBlock and toBlock"
+// @Param topic0 query string false "comma separated OR-ed values of the first topic"
+// @Param topic1 query string false "comma separated OR-ed values of the second topic"
+// @Param topic2 query string false "comma separated OR-ed values of the third topic"
+// @Param topic3 query string false "comma separated OR-ed values of the fourth topic"
+// @Param limit query int false "maximum number of events in the page, 100 by default and at most 1000"
+// @Param cursor query string false "the next cursor of the previous page"
+// @Success 200 {object} models.EventResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -31,7 +42,43 @@ func (h *handler) GetEventsByAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	events, err := h.blockProcessService.GetEventsByAddress(r.Context(), address)
+	topics, err := parseTopics(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	filter := blocksearch.EventFilter{Topics: topics}
+	if filter.Role, err = parseRole(r); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+	if filter.FromBlock, err = parseBlockRef(r, "fromBlock"); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+	if filter.ToBlock, err = parseBlockRef(r, "toBlock"); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+	if filter.BlockHash, err = parseHash(r, "blockHash"); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+	if filter.BlockHash != nil && (filter.FromBlock != nil || filter.ToBlock != nil) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: blockHash cannot be combined with fromBlock or toBlock")
+		return
+	}
+
+	limit, err := parseLimit(r, blocksearch.DefaultPageLimit, blocksearch.MaxPageLimit)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	// the logs are indexed by the checksummed address
+	page := blocksearch.Page{Limit: limit, Cursor: r.URL.Query().Get("cursor")}
+	events, err := h.blockProcessService.GetEventsByAddress(r.Context(), common.HexToAddress(address).Hex(), filter, page)
 	if err != nil {
 		h.handleError(w, err)
 		return
@@ -40,6 +87,7 @@ func (h *handler) GetEventsByAddress(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, models.EventResponse{
 		Status:  models.StatusSuccess,
 		Address: address,
-		Events:  events,
+		Events:  h.decodeEvents(models.NewEvents(events.Events)),
+		Next:    events.Next,
 	})
 }
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/models"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/mux"
+)
+
+const sseHeartbeatInterval = 15 * time.Second // keeps the idle streams open through the proxies
+
+// Event stream API endpoint
+// @Summary Stream the events of an address
+// @Description Push the new events of a specific address as server-sent events, as soon as they are indexed. The id of each event is its number in the ingestion sequence, so a reconnecting client replays the events it missed from the window of the recent blocks by the Last-Event-ID header, including the events of the backfilled and retried blocks. An id ahead of the sequence, e.g. of another instance, replays the whole window. The events removed by a chain reorganization are pushed again with removed set, under a new id
+// @Tags Logs
+// @Produce text/event-stream
+// @Param address path string true "an address in the blockchain"
+// @Param role query string false "emitter (default) for the events emitted by the address, participant for the events referring to the address in their indexed topics, or any"
+// @Param topic0 query string false "comma separated OR-ed values of the first topic"
+// @Param topic1 query string false "comma separated OR-ed values of the second topic"
+// @Param topic2 query string false "comma separated OR-ed values of the third topic"
+// @Param topic3 query string false "comma separated OR-ed values of the fourth topic"
+// @Param Last-Event-ID header string false "the id of the last received event, to replay the events after it"
+// @Param lastEventId query string false "the same as the Last-Event-ID header, for the clients which cannot set it"
+// @Success 200 {object} models.Event
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /events/{address}/stream [get]
+
+// StreamEventsByAddress streams the new events related to a specific address
+func (h *handler) StreamEventsByAddress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	address := vars["address"]
+
+	if !common.IsHexAddress(address) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: address is not a valid hex address")
+		return
+	}
+
+	topics, err := parseTopics(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	filter := blocksearch.EventFilter{Topics: topics}
+	if filter.Role, err = parseRole(r); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.respondWithError(w, http.StatusInternalServerError, "streaming is not supported by the connection")
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var lastSequence uint64
+	if lastEventID != "" {
+		if lastSequence, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid input: last event id is not a valid event id")
+			return
+		}
+	}
+
+	// subscribed before the replay, so the events indexed meanwhile are not missed. They are received twice then, and the live
+	// copies of the replayed events are skipped by their sequence
+	addressHex := common.HexToAddress(address).Hex()
+	sub := h.feed.SubscribeLogs()
+	defer sub.Unsubscribe()
+
+	var replayed []chainfeed.Log
+	if lastEventID != "" {
+		// an id ahead of the feed was not given by it, e.g. by another instance, so the whole journal is replayed rather than
+		// skipping the events until the sequence catches up
+		if lastSequence > h.feed.Sequence() {
+			lastSequence = 0
+		}
+		replayed = h.feed.LogsAfter(lastSequence)
+	}
+
+	// the stream outlives the write timeout of the server
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, txLog := range replayed {
+		lastSequence = txLog.Sequence
+		if !filter.Match(addressHex, txLog.Log) {
+			continue
+		}
+		if err := h.writeSSEEvent(w, txLog); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case txLog, ok := <-sub.C():
+			if !ok {
+				return // dropped for falling behind, the client resumes by its last event id
+			}
+			if txLog.Sequence <= lastSequence || !filter.Match(addressHex, txLog.Log) {
+				continue
+			}
+
+			if err := h.writeSSEEvent(w, txLog); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes a log as a server-sent event, decoded if its contract ABI is registered, with its sequence as the id
+func (h *handler) writeSSEEvent(w io.Writer, txLog chainfeed.Log) error {
+	event := h.decodeEvents(models.NewEvents([]types.Log{txLog.Log}))[0]
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", txLog.Sequence, data)
+	return err
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/abiregistry"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is a server-sent event of the stream
+type sseEvent struct {
+	id  string
+	log struct {
+		BlockNumber hexutil.Uint64 `json:"blockNumber"`
+		BlockHash   common.Hash    `json:"blockHash"`
+		Removed     bool           `json:"removed"`
+	}
+}
+
+// newStreamServer serves the event streams of a feed
+func newStreamServer(t *testing.T, feed chainfeed.Service) *httptest.Server {
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	registry, err := abiregistry.NewRegistry(conf, logger)
+	require.NoError(t, err)
+
+	h := &handler{abiRegistry: registry, feed: feed}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/events/{address}/stream", h.StreamEventsByAddress)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// openStream opens the event stream of an address, resuming after a last event id if set
+func openStream(t *testing.T, server *httptest.Server, address common.Address, lastEventID string) *http.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/events/"+address.Hex()+"/stream", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+
+	return response
+}
+
+// readSSEEvents reads a number of events of a stream
+func readSSEEvents(t *testing.T, reader *bufio.Reader, count int) []sseEvent {
+	events := make([]sseEvent, 0, count)
+	var event sseEvent
+	for len(events) < count {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.log))
+		case line == "" && event.id != "":
+			events = append(events, event)
+			event = sseEvent{}
+		}
+	}
+
+	return events
+}
+
+func TestStreamEventsReplay(t *testing.T) {
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 50}}
+	feed := chainfeed.NewService(conf, log.New(os.Stdout, "app", log.LstdFlags))
+	server := newStreamServer(t, feed)
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	other := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	orphan, canonical := common.HexToHash("0x01"), common.HexToHash("0x02")
+
+	sub := feed.SubscribeLogs()
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10, BlockHash: orphan, Index: 0}}, true)
+	seen := <-sub.C()
+	sub.Unsubscribe()
+
+	// missed by the client: a log of another address, a backfilled block, the removal of the seen log and its replacement
+	feed.PublishLogs([]types.Log{{Address: other, BlockNumber: 10, BlockHash: orphan, Index: 1}}, true)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 8, Index: 3}}, false)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10, BlockHash: orphan, Index: 0, Removed: true}}, true)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10, BlockHash: canonical, Index: 0}}, true)
+
+	response := openStream(t, server, token, strconv.FormatUint(seen.Sequence, 10))
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	reader := bufio.NewReader(response.Body)
+
+	events := readSSEEvents(t, reader, 3)
+	assert.Equal(t, hexutil.Uint64(8), events[0].log.BlockNumber, "the backfilled log is replayed, although not live")
+	assert.True(t, events[1].log.Removed, "the removal is replayed")
+	assert.Equal(t, orphan, events[1].log.BlockHash)
+	assert.Equal(t, canonical, events[2].log.BlockHash)
+	assert.NotEqual(t, events[1].id, strconv.FormatUint(seen.Sequence, 10), "the removal has its own id")
+
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 11}}, true)
+	live := readSSEEvents(t, reader, 1)[0]
+	assert.Equal(t, hexutil.Uint64(11), live.log.BlockNumber)
+	lastID, err := strconv.ParseUint(events[2].id, 10, 64)
+	require.NoError(t, err)
+	liveID, err := strconv.ParseUint(live.id, 10, 64)
+	require.NoError(t, err)
+	assert.Greater(t, liveID, lastID)
+}
+
+func TestStreamEventsReplayWithoutLogs(t *testing.T) {
+	feed := chainfeed.NewService(config.Config{}, log.New(os.Stdout, "app", log.LstdFlags))
+	server := newStreamServer(t, feed)
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+
+	response := openStream(t, server, address, "1")
+	assert.Equal(t, http.StatusOK, response.StatusCode, "an address without logs replays nothing")
+
+	response = openStream(t, server, address, "not-a-sequence")
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestStreamEventsReplayUnknownID(t *testing.T) {
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 50}}
+	feed := chainfeed.NewService(conf, log.New(os.Stdout, "app", log.LstdFlags))
+	server := newStreamServer(t, feed)
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10}}, true)
+
+	// an id of another instance, ahead of the sequence of this feed
+	response := openStream(t, server, token, strconv.FormatUint(feed.Sequence()+1_000_000, 10))
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	reader := bufio.NewReader(response.Body)
+
+	replayed := readSSEEvents(t, reader, 1)[0]
+	assert.Equal(t, hexutil.Uint64(10), replayed.log.BlockNumber, "the whole journal is replayed")
+
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 11}}, true)
+	live := readSSEEvents(t, reader, 1)[0]
+	assert.Equal(t, hexutil.Uint64(11), live.log.BlockNumber, "the live events are not skipped")
+}
@@ -1,25 +1,64 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"ethereum-tracker-app/internal/services/abiregistry"
+	"ethereum-tracker-app/internal/services/blockprocessor"
 	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/internal/services/rpcfacade"
+	"ethereum-tracker-app/internal/services/webhook"
 	"ethereum-tracker-app/models"
 	"ethereum-tracker-app/pkg/customerror"
 	"net/http"
+	"strings"
 )
 
 type Handler interface {
 	GetEventsByAddress(w http.ResponseWriter, r *http.Request)
+	StreamEventsByAddress(w http.ResponseWriter, r *http.Request)
+	GetTransfersByAddress(w http.ResponseWriter, r *http.Request)
+	GetTransfersByToken(w http.ResponseWriter, r *http.Request)
+	GetTransactionsByAddress(w http.ResponseWriter, r *http.Request)
+	GetBlock(w http.ResponseWriter, r *http.Request)
+	GetBlockTransactions(w http.ResponseWriter, r *http.Request)
+	GetTransaction(w http.ResponseWriter, r *http.Request)
+	RegisterABI(w http.ResponseWriter, r *http.Request)
+	GetABI(w http.ResponseWriter, r *http.Request)
+	GetSyncStatus(w http.ResponseWriter, r *http.Request)
+	GetDeadLetters(w http.ResponseWriter, r *http.Request)
+	ReprocessDeadLetters(w http.ResponseWriter, r *http.Request)
+	ServeJSONRPC(w http.ResponseWriter, r *http.Request)
+	ServeWebSocket(w http.ResponseWriter, r *http.Request)
+	RegisterWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhooks(w http.ResponseWriter, r *http.Request)
+	GetWebhook(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	EnableWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhookDeliveries(w http.ResponseWriter, r *http.Request)
 }
 
 type handler struct {
 	blockProcessService blocksearch.Service
+	ethClient           blockprocessor.Service
+	abiRegistry         abiregistry.Service
+	rpcFacade           rpcfacade.Service
+	feed                chainfeed.Service
+	webhooks            webhook.Service
+	adminToken          string // bearer token of the admin endpoints, which are closed without it
 }
 
-func NewHandler(blockProcessorSrv blocksearch.Service) Handler {
+func NewHandler(blockProcessorSrv blocksearch.Service, ethClient blockprocessor.Service, abiRegistry abiregistry.Service, rpcFacade rpcfacade.Service, feed chainfeed.Service, webhooks webhook.Service, adminToken string) Handler {
 	return &handler{
 		blockProcessService: blockProcessorSrv,
+		ethClient:           ethClient,
+		abiRegistry:         abiRegistry,
+		rpcFacade:           rpcFacade,
+		feed:                feed,
+		webhooks:            webhooks,
+		adminToken:          adminToken,
 	}
 }
 
@@ -42,6 +81,8 @@ func (h *handler) handleError(w http.ResponseWriter, err error) {
 			h.respondWithError(w, http.StatusNotFound, customErr.Message)
 		case customerror.ErrCodeInvalidInput:
 			h.respondWithError(w, http.StatusBadRequest, customErr.Message)
+		case customerror.ErrCodeUnauthorized:
+			h.respondWithError(w, http.StatusUnauthorized, customErr.Message)
 		default:
 			h.respondWithError(w, http.StatusInternalServerError, customErr.Message)
 		}
@@ -51,3 +92,29 @@ func (h *handler) handleError(w http.ResponseWriter, err error) {
 
 	h.respondWithError(w, http.StatusInternalServerError, err.Error())
 }
+
+// authorizeAdmin checks the bearer token of a request to an admin endpoint, and responds with the error if it is not authorized.
+// The admin endpoints are closed if ADMIN_TOKEN is not set
+func (h *handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		h.handleError(w, customerror.NewUnauthorizedError("Unauthorized: the endpoint is closed, as ADMIN_TOKEN is not set", nil))
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		h.handleError(w, customerror.NewUnauthorizedError("Unauthorized: the bearer token is missing or invalid", nil))
+		return false
+	}
+
+	return true
+}
+
+// decodeEvents decodes the events whose contract ABI is registered
+func (h *handler) decodeEvents(events []models.Event) []models.Event {
+	for i := range events {
+		events[i].EventName, events[i].DecodedArgs, _ = h.abiRegistry.DecodeLog(events[i].Log)
+	}
+
+	return events
+}
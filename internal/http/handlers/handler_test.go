@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizeAdmin(t *testing.T) {
+	type testCase struct {
+		name          string
+		adminToken    string
+		authorization string
+		authorized    bool
+	}
+
+	testcases := []testCase{
+		{name: "the token", adminToken: "t0ken", authorization: "Bearer t0ken", authorized: true},
+		{name: "no token", adminToken: "t0ken"},
+		{name: "a wrong token", adminToken: "t0ken", authorization: "Bearer t0ke"},
+		{name: "a longer token", adminToken: "t0ken", authorization: "Bearer t0ken0"},
+		{name: "the token without the bearer scheme", adminToken: "t0ken", authorization: "t0ken"},
+		{name: "closed without an admin token", authorization: "Bearer "},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{adminToken: tt.adminToken}
+			request := httptest.NewRequest(http.MethodPost, "/v1/deadletters/1/reprocess", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+
+			assert.Equal(t, tt.authorized, h.authorizeAdmin(recorder, request))
+			if !tt.authorized {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const maxTopicPositions = 4
+
+// parseTopics parses the topic0..topic3 query parameters. Each one accepts a list of OR-ed values, either comma separated
+// or repeated, like the topics of eth_getLogs
+func parseTopics(r *http.Request) ([][]common.Hash, error) {
+	query := r.URL.Query()
+	topics := make([][]common.Hash, 0, maxTopicPositions)
+	for position := 0; position < maxTopicPositions; position++ {
+		sub := make([]common.Hash, 0)
+		for _, param := range query[fmt.Sprintf("topic%d", position)] {
+			for _, value := range strings.Split(param, ",") {
+				value = strings.TrimSpace(value)
+				if value == "" {
+					continue
+				}
+
+				topic, err := hexutil.Decode(value)
+				if err != nil || len(topic) != common.HashLength {
+					return nil, fmt.Errorf("topic%d value %s is not a valid 32 bytes hex", position, value)
+				}
+				sub = append(sub, common.BytesToHash(topic))
+			}
+		}
+		topics = append(topics, sub)
+	}
+
+	// trailing wildcard positions do not restrict anything
+	for len(topics) > 0 && len(topics[len(topics)-1]) == 0 {
+		topics = topics[:len(topics)-1]
+	}
+
+	return topics, nil
+}
+
+// parseRole parses the role query parameter of the events of an address. A missing parameter is empty
+func parseRole(r *http.Request) (models.AddressRole, error) {
+	switch role := models.AddressRole(r.URL.Query().Get("role")); role {
+	case "", models.AddressRoleEmitter, models.AddressRoleParticipant, models.AddressRoleAny:
+		return role, nil
+	default:
+		return "", errors.New("role must be one of emitter, participant and any")
+	}
+}
+
+// parseLimit parses the limit query parameter of a paginated endpoint
+func parseLimit(r *http.Request, defaultLimit, maxLimit int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+	}
+
+	return limit, nil
+}
+
+// parseBlockRef parses a block query parameter, either a block number in decimal or hex, or one of the latest, safe
+// and finalized tags. A missing parameter is nil
+func parseBlockRef(r *http.Request, name string) (*blocksearch.BlockRef, error) {
+	return parseBlockRefValue(name, r.URL.Query().Get(name))
+}
+
+// parseBlockRefValue parses a block number in decimal or hex, or a block tag. An empty value is nil
+func parseBlockRefValue(name, value string) (*blocksearch.BlockRef, error) {
+	value = strings.TrimSpace(value)
+	switch tag := models.BlockTag(value); tag {
+	case "":
+		return nil, nil
+	case models.BlockTagLatest, models.BlockTagSafe, models.BlockTagFinalized:
+		return &blocksearch.BlockRef{Tag: tag}, nil
+	}
+
+	var (
+		number uint64
+		err    error
+	)
+	if strings.HasPrefix(value, "0x") {
+		number, err = hexutil.DecodeUint64(value)
+	} else {
+		number, err = strconv.ParseUint(value, 10, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a block number or one of latest, safe and finalized", name)
+	}
+
+	return &blocksearch.BlockRef{Number: number}, nil
+}
+
+// parseHash parses a 32 bytes hex query parameter. A missing parameter is nil
+func parseHash(r *http.Request, name string) (*common.Hash, error) {
+	return parseHashValue(name, r.URL.Query().Get(name))
+}
+
+// parseHashValue parses a 32 bytes hex. An empty value is nil
+func parseHashValue(name, value string) (*common.Hash, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := hexutil.Decode(value)
+	if err != nil || len(decoded) != common.HashLength {
+		return nil, fmt.Errorf("%s is not a valid 32 bytes hex", name)
+	}
+	hash := common.BytesToHash(decoded)
+
+	return &hash, nil
+}
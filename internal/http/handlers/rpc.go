@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	rpcVersion      = "2.0"
+	maxRPCBodySize  = 1 << 20 // 1MB
+	maxRPCBatchSize = 100
+
+	rpcParseErrorCode     = -32700
+	rpcInvalidRequestCode = -32600
+	rpcMethodNotFoundCode = -32601
+	rpcInvalidParamsCode  = -32602
+	rpcInternalErrorCode  = -32603
+	rpcServerErrorCode    = -32000 // the generic server error of go-ethereum, e.g. an unknown block or a range out of the indexed window
+)
+
+// rpcCallError is an error of a json-rpc call with its json-rpc code
+type rpcCallError struct {
+	code    int
+	message string
+}
+
+func (e *rpcCallError) Error() string {
+	return e.message
+}
+
+// rpcFilterCriteria is the filter object of eth_getLogs. The address is a single address or a list, and each topic position is
+// null, a single topic or a list of topics
+type rpcFilterCriteria struct {
+	BlockHash *common.Hash     `json:"blockHash"`
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	Address   interface{}      `json:"address"`
+	Topics    []interface{}    `json:"topics"`
+}
+
+// JSON-RPC API endpoint
+// @Summary Ethereum JSON-RPC facade
+// @Description Answer eth_getLogs, eth_getBlockByNumber, eth_getTransactionReceipt, eth_blockNumber and eth_chainId from the local index, in the json-rpc 2.0 format of go-ethereum. Batch requests are supported
+// @Tags JSON-RPC
+// @Accept json
+// @Produce json
+// @Param request body models.RPCRequest true "a json-rpc request, or a batch of them"
+// @Success 200 {object} models.RPCResponse
+// @Success 204 "the request is a notification, or a batch of notifications"
+// @Router /rpc [post]
+
+// ServeJSONRPC answers the json-rpc requests of the ethereum clients from the local index
+func (h *handler) ServeJSONRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodySize))
+	if err != nil {
+		h.respondWithJSON(w, http.StatusOK, rpcErrorResponse(nil, rpcParseErrorCode, "request body is too large or unreadable"))
+		return
+	}
+
+	answer := h.answerRPC(r.Context(), body, h.dispatchRPC)
+	if answer == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, answer)
+}
+
+// rpcDispatcher calls the method of a json-rpc request with its positional params
+type rpcDispatcher func(ctx context.Context, method string, params json.RawMessage) (interface{}, error)
+
+// answerRPC answers a json-rpc message, either a single request or a batch of them. The notifications are not answered, so the
+// answer is nil if the message has only notifications
+func (h *handler) answerRPC(ctx context.Context, body []byte, dispatch rpcDispatcher) interface{} {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		response, ok := h.callRPC(ctx, body, dispatch)
+		if !ok {
+			return nil
+		}
+		return response
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return rpcErrorResponse(nil, rpcParseErrorCode, "parse error")
+	}
+	if len(batch) == 0 || len(batch) > maxRPCBatchSize {
+		return rpcErrorResponse(nil, rpcInvalidRequestCode, fmt.Sprintf("batch must have 1 to %d requests", maxRPCBatchSize))
+	}
+
+	responses := make([]models.RPCResponse, 0, len(batch))
+	for _, message := range batch {
+		if response, ok := h.callRPC(ctx, message, dispatch); ok {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return responses
+}
+
+// callRPC answers a single json-rpc request. A valid request without an id is a notification, which is called but not answered,
+// so the response is dropped
+func (h *handler) callRPC(ctx context.Context, message json.RawMessage, dispatch rpcDispatcher) (models.RPCResponse, bool) {
+	var request models.RPCRequest
+	if err := json.Unmarshal(message, &request); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return rpcErrorResponse(nil, rpcParseErrorCode, "parse error"), true
+		}
+		return rpcErrorResponse(nil, rpcInvalidRequestCode, "invalid request"), true
+	}
+	if request.JSONRPC != rpcVersion || request.Method == "" {
+		return rpcErrorResponse(request.ID, rpcInvalidRequestCode, "invalid request"), true
+	}
+	answered := request.ID != nil // an explicit null id is a request, not a notification
+
+	result, err := dispatch(ctx, request.Method, request.Params)
+	if err != nil {
+		code, message := rpcErrorOf(err)
+		return rpcErrorResponse(request.ID, code, message), answered
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return rpcErrorResponse(request.ID, rpcInternalErrorCode, err.Error()), answered
+	}
+
+	return models.RPCResponse{JSONRPC: rpcVersion, ID: rpcID(request.ID), Result: encoded}, answered
+}
+
+// dispatchRPC calls the method of a json-rpc request with its positional params
+func (h *handler) dispatchRPC(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "eth_blockNumber":
+		if err := parseRPCParams(params, 0); err != nil {
+			return nil, err
+		}
+		number, err := h.rpcFacade.BlockNumber(ctx)
+		return hexutil.Uint64(number), err
+
+	case "eth_chainId":
+		if err := parseRPCParams(params, 0); err != nil {
+			return nil, err
+		}
+		chainID, err := h.ethClient.GetChainID(ctx)
+		return (*hexutil.Big)(chainID), err
+
+	case "eth_getLogs":
+		var criteria rpcFilterCriteria
+		if err := parseRPCParams(params, 1, &criteria); err != nil {
+			return nil, err
+		}
+		query, err := criteria.toFilterQuery()
+		if err != nil {
+			return nil, err
+		}
+		return h.rpcFacade.GetLogs(ctx, query)
+
+	case "eth_getBlockByNumber":
+		var (
+			number rpc.BlockNumber
+			fullTx bool
+		)
+		if err := parseRPCParams(params, 2, &number, &fullTx); err != nil {
+			return nil, err
+		}
+		return h.rpcFacade.GetBlockByNumber(ctx, number, fullTx)
+
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		if err := parseRPCParams(params, 1, &hash); err != nil {
+			return nil, err
+		}
+		return h.rpcFacade.GetTransactionReceipt(ctx, hash)
+
+	case "eth_subscribe", "eth_unsubscribe":
+		return nil, &rpcCallError{code: rpcMethodNotFoundCode, message: "notifications not supported"} // only over the websocket
+
+	default:
+		return nil, &rpcCallError{code: rpcMethodNotFoundCode, message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+	}
+}
+
+// parseRPCParams decodes the positional params of a json-rpc request into the arguments, the first required ones are mandatory
+func parseRPCParams(params json.RawMessage, required int, args ...interface{}) error {
+	var values []json.RawMessage
+	if len(params) != 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &values); err != nil {
+			return &rpcCallError{code: rpcInvalidParamsCode, message: "non-array args"}
+		}
+	}
+
+	if len(values) > len(args) {
+		return &rpcCallError{code: rpcInvalidParamsCode, message: fmt.Sprintf("too many arguments, want at most %d", len(args))}
+	}
+	if len(values) < required {
+		return &rpcCallError{code: rpcInvalidParamsCode, message: fmt.Sprintf("missing value for required argument %d", len(values))}
+	}
+
+	for i, value := range values {
+		if err := json.Unmarshal(value, args[i]); err != nil {
+			return &rpcCallError{code: rpcInvalidParamsCode, message: fmt.Sprintf("invalid argument %d: %v", i, err)}
+		}
+	}
+
+	return nil
+}
+
+// toFilterQuery converts the filter object of eth_getLogs to a filter query, the same as go-ethereum. A null within the
+// list of a topic position matches any topic
+func (c rpcFilterCriteria) toFilterQuery() (ethereum.FilterQuery, error) {
+	query := ethereum.FilterQuery{BlockHash: c.BlockHash}
+	if c.FromBlock != nil {
+		query.FromBlock = big.NewInt(c.FromBlock.Int64())
+	}
+	if c.ToBlock != nil {
+		query.ToBlock = big.NewInt(c.ToBlock.Int64())
+	}
+
+	invalidAddress := &rpcCallError{code: rpcInvalidParamsCode, message: "invalid argument 0: invalid address"}
+	switch address := c.Address.(type) {
+	case nil:
+	case string:
+		if !common.IsHexAddress(address) {
+			return query, invalidAddress
+		}
+		query.Addresses = []common.Address{common.HexToAddress(address)}
+	case []interface{}:
+		for _, item := range address {
+			value, ok := item.(string)
+			if !ok || !common.IsHexAddress(value) {
+				return query, invalidAddress
+			}
+			query.Addresses = append(query.Addresses, common.HexToAddress(value))
+		}
+	default:
+		return query, invalidAddress
+	}
+
+	invalidTopic := &rpcCallError{code: rpcInvalidParamsCode, message: "invalid argument 0: invalid topic(s)"}
+	query.Topics = make([][]common.Hash, len(c.Topics))
+	for i, position := range c.Topics {
+		switch topic := position.(type) {
+		case nil:
+		case string:
+			hash, err := parseHashValue("topic", topic)
+			if err != nil || hash == nil {
+				return query, invalidTopic
+			}
+			query.Topics[i] = []common.Hash{*hash}
+		case []interface{}:
+			for _, item := range topic {
+				if item == nil {
+					query.Topics[i] = nil
+					break
+				}
+				value, ok := item.(string)
+				if !ok {
+					return query, invalidTopic
+				}
+				hash, err := parseHashValue("topic", value)
+				if err != nil || hash == nil {
+					return query, invalidTopic
+				}
+				query.Topics[i] = append(query.Topics[i], *hash)
+			}
+		default:
+			return query, invalidTopic
+		}
+	}
+
+	return query, nil
+}
+
+// rpcErrorOf gets the json-rpc code and message of an error of a call
+func rpcErrorOf(err error) (int, string) {
+	var callErr *rpcCallError
+	if errors.As(err, &callErr) {
+		return callErr.code, callErr.message
+	}
+
+	var customErr *customerror.Error
+	if errors.As(err, &customErr) {
+		switch customErr.Code {
+		case customerror.ErrCodeInvalidInput:
+			return rpcInvalidParamsCode, customErr.Message
+		case customerror.ErrCodeNotFound, customerror.ErrCodeOutOfRange:
+			return rpcServerErrorCode, customErr.Message
+		default:
+			return rpcInternalErrorCode, customErr.Message
+		}
+	}
+
+	return rpcInternalErrorCode, err.Error()
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, message string) models.RPCResponse {
+	return models.RPCResponse{JSONRPC: rpcVersion, ID: rpcID(id), Error: &models.RPCError{Code: code, Message: message}}
+}
+
+// rpcID gets the id of a response, which is null when the id of the request is unknown
+func rpcID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+
+	return id
+}
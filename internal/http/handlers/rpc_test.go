@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"ethereum-tracker-app/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnswerRPCNotifications(t *testing.T) {
+	h := &handler{}
+	ctx := context.Background()
+	var called []string
+	dispatch := func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+		called = append(called, method)
+		return "0x1", nil
+	}
+
+	answer := h.answerRPC(ctx, []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber"}`), dispatch)
+	assert.Nil(t, answer, "a notification is not answered")
+	assert.Equal(t, []string{"eth_blockNumber"}, called, "a notification is still called")
+
+	answer = h.answerRPC(ctx, []byte(`{"jsonrpc":"2.0","id":null,"method":"eth_blockNumber"}`), dispatch)
+	require.IsType(t, models.RPCResponse{}, answer, "a null id is a request")
+	assert.Equal(t, json.RawMessage("null"), answer.(models.RPCResponse).ID)
+
+	answer = h.answerRPC(ctx, []byte(`[
+		{"jsonrpc":"2.0","method":"eth_blockNumber"},
+		{"jsonrpc":"2.0","id":7,"method":"eth_blockNumber"},
+		{"jsonrpc":"1.0","method":"eth_blockNumber"}
+	]`), dispatch)
+	require.IsType(t, []models.RPCResponse{}, answer)
+	responses := answer.([]models.RPCResponse)
+	require.Len(t, responses, 2, "a batch omits the notifications, not the invalid requests")
+	assert.Equal(t, json.RawMessage("7"), responses[0].ID)
+	require.NotNil(t, responses[1].Error)
+	assert.Equal(t, rpcInvalidRequestCode, responses[1].Error.Code)
+
+	answer = h.answerRPC(ctx, []byte(`[{"jsonrpc":"2.0","method":"eth_blockNumber"}]`), dispatch)
+	assert.Nil(t, answer, "a batch of notifications is not answered")
+}
+
+func TestServeJSONRPCNotification(t *testing.T) {
+	h := &handler{}
+
+	recorder := httptest.NewRecorder()
+	h.ServeJSONRPC(recorder, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_unknown"}`)))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	h.ServeJSONRPC(recorder, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_unknown"}`)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response models.RPCResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, rpcMethodNotFoundCode, response.Error.Code)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/models"
+	"net/http"
+)
+
+// Status API endpoint
+// @Summary Get the ingestion status
+// @Description Retrieve the state of the connection to the Ethereum node and the progress of the ingestion
+// @Tags Status
+// @Produce json
+// @Success 200 {object} models.SyncStatusResponse
+// @Router /status [get]
+
+// GetSyncStatus Gets the state of the ingestion, for the operators
+func (h *handler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, models.SyncStatusResponse{
+		Status: models.StatusSuccess,
+		Sync:   h.ethClient.GetSyncStatus(r.Context()),
+	})
+}
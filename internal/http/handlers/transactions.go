@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Transactions API endpoint
+// @Summary Get a transaction
+// @Description Retrieve an indexed transaction with its block, receipt data and logs
+// @Tags Transactions
+// @Produce json
+// @Param hash path string true "hash of the transaction"
+// @Success 200 {object} models.TransactionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /transactions/{hash} [get]
+
+// GetTransaction Gets an indexed transaction, which is not found when it is outside the window of the recent blocks
+func (h *handler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	hash, err := parseHashValue("transaction hash", mux.Vars(r)["hash"])
+	if err != nil || hash == nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: transaction hash is not a valid 32 bytes hex")
+		return
+	}
+
+	transaction, err := h.blockProcessService.GetTransaction(r.Context(), *hash)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	transaction.Logs = h.decodeEvents(transaction.Logs)
+	h.respondWithJSON(w, http.StatusOK, models.TransactionResponse{
+		Status:      models.StatusSuccess,
+		Transaction: transaction,
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/internal/services/blocksearch"
+	"ethereum-tracker-app/models"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+// Transfers API endpoint
+// @Summary Get token transfers by address
+// @Description Retrieve the erc-20, erc-721 and erc-1155 token transfers sent or received by an address
+// @Tags Transfers
+// @Produce json
+// @Param address path string true "an address in the blockchain"
+// @Param direction query string false "in, out or any (default)"
+// @Param token query string false "address of a token contract"
+// @Param limit query int false "maximum number of transfers in the page, 100 by default and at most 1000"
+// @Param cursor query string false "the next cursor of the previous page"
+// @Success 200 {object} models.TransfersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /addresses/{address}/transfers [get]
+
+// GetTransfersByAddress Gets the token transfers of a specific address
+func (h *handler) GetTransfersByAddress(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: address is not a valid hex address")
+		return
+	}
+
+	filter := blocksearch.TransferFilter{Direction: models.TransferDirection(r.URL.Query().Get("direction"))}
+	switch filter.Direction {
+	case "", models.TransferDirectionIn, models.TransferDirectionOut, models.TransferDirectionAny:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: direction must be one of in, out and any")
+		return
+	}
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		if !common.IsHexAddress(token) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid input: token is not a valid hex address")
+			return
+		}
+		tokenAddress := common.HexToAddress(token)
+		filter.Token = &tokenAddress
+	}
+
+	limit, err := parseLimit(r, blocksearch.DefaultPageLimit, blocksearch.MaxPageLimit)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	// the transfers are indexed by the checksummed address
+	page := blocksearch.Page{Limit: limit, Cursor: r.URL.Query().Get("cursor")}
+	transfers, err := h.blockProcessService.GetTransfersByAddress(r.Context(), common.HexToAddress(address).Hex(), filter, page)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.TransfersResponse{
+		Status:    models.StatusSuccess,
+		Address:   address,
+		Transfers: transfers.Transfers,
+		Next:      transfers.Next,
+	})
+}
+
+// Token transfers API endpoint
+// @Summary Get token transfers by token
+// @Description Retrieve the erc-20, erc-721 and erc-1155 token transfers of a token contract
+// @Tags Transfers
+// @Produce json
+// @Param token path string true "address of a token contract"
+// @Param limit query int false "maximum number of transfers in the page, 100 by default and at most 1000"
+// @Param cursor query string false "the next cursor of the previous page"
+// @Success 200 {object} models.TransfersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /tokens/{token}/transfers [get]
+
+// GetTransfersByToken Gets the transfers of a specific token contract
+func (h *handler) GetTransfersByToken(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	if !common.IsHexAddress(token) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: token is not a valid hex address")
+		return
+	}
+
+	limit, err := parseLimit(r, blocksearch.DefaultPageLimit, blocksearch.MaxPageLimit)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	// the transfers are indexed by the checksummed token address
+	page := blocksearch.Page{Limit: limit, Cursor: r.URL.Query().Get("cursor")}
+	transfers, err := h.blockProcessService.GetTransfersByToken(r.Context(), common.HexToAddress(token).Hex(), page)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.TransfersResponse{
+		Status:    models.StatusSuccess,
+		Address:   token,
+		Transfers: transfers.Transfers,
+		Next:      transfers.Next,
+	})
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ethereum-tracker-app/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const maxWebhookRequestSize = 1 << 16
+
+// Register webhook API endpoint
+// @Summary Register a webhook
+// @Description Register a url notified of the new events of a list of addresses, optionally filtered by their topics with the semantics of eth_getLogs. The matching events are posted in batches as soon as they are indexed, signed by hmac-sha256 in the X-Webhook-Signature header if a secret is given. A failed batch is retried with backoff, and the webhook is disabled after repeated failures. The delivery is at-least-once within the window of the recent blocks: the webhook resumes after its deliveredBlock on a restart, so an event may be delivered again and is identified by its block hash and log index
+// @Tags Webhooks
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookRequest true "url, addresses, topics and optional secret of the webhook"
+// @Success 201 {object} models.WebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /webhooks [post]
+
+// RegisterWebhook Registers a webhook
+func (h *handler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var request models.WebhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookRequestSize)).Decode(&request); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid input: webhook is not a valid json")
+		return
+	}
+
+	webhook, err := h.webhooks.Register(r.Context(), request)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, models.WebhookResponse{
+		Status:  models.StatusCreated,
+		Webhook: webhook,
+	})
+}
+
+// Get webhooks API endpoint
+// @Summary Get the webhooks
+// @Description Retrieve the registered webhooks, in the order of their registration
+// @Tags Webhooks
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.WebhooksResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /webhooks [get]
+
+// GetWebhooks Gets the registered webhooks
+func (h *handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.WebhooksResponse{
+		Status:   models.StatusSuccess,
+		Webhooks: h.webhooks.GetWebhooks(r.Context()),
+	})
+}
+
+// Get webhook API endpoint
+// @Summary Get a webhook
+// @Description Retrieve a registered webhook, with its consecutive failures and the reason it was disabled
+// @Tags Webhooks
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "id of the webhook"
+// @Success 200 {object} models.WebhookResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id} [get]
+
+// GetWebhook Gets a registered webhook
+func (h *handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	webhook, err := h.webhooks.GetWebhook(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.WebhookResponse{
+		Status:  models.StatusSuccess,
+		Webhook: webhook,
+	})
+}
+
+// Delete webhook API endpoint
+// @Summary Delete a webhook
+// @Description Delete a webhook, dropping the events not delivered yet
+// @Tags Webhooks
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "id of the webhook"
+// @Success 200 {object} models.WebhookResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /webhooks/{id} [delete]
+
+// DeleteWebhook Deletes a webhook
+func (h *handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	webhook, err := h.webhooks.DeleteWebhook(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.WebhookResponse{
+		Status:  models.StatusSuccess,
+		Webhook: webhook,
+	})
+}
+
+// Enable webhook API endpoint
+// @Summary Enable a webhook
+// @Description Enable a webhook disabled after its failed deliveries. The events queued before it was disabled are delivered, the events indexed while it was disabled are not
+// @Tags Webhooks
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "id of the webhook"
+// @Success 200 {object} models.WebhookResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /webhooks/{id}/enable [post]
+
+// EnableWebhook Enables a disabled webhook
+func (h *handler) EnableWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	webhook, err := h.webhooks.EnableWebhook(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.WebhookResponse{
+		Status:  models.StatusSuccess,
+		Webhook: webhook,
+	})
+}
+
+// Get webhook deliveries API endpoint
+// @Summary Get the deliveries of a webhook
+// @Description Retrieve the latest delivery attempts of a webhook, the newest first. The retries of a batch share its delivery id
+// @Tags Webhooks
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "id of the webhook"
+// @Success 200 {object} models.WebhookDeliveriesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id}/deliveries [get]
+
+// GetWebhookDeliveries Gets the latest delivery attempts of a webhook
+func (h *handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	deliveries, err := h.webhooks.GetDeliveries(r.Context(), id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, models.WebhookDeliveriesResponse{
+		Status:     models.StatusSuccess,
+		WebhookID:  id,
+		Deliveries: deliveries,
+	})
+}
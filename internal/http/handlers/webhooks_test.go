@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/abiregistry"
+	"ethereum-tracker-app/internal/services/webhook"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooksAuthorization(t *testing.T) {
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	registry, err := abiregistry.NewRegistry(conf, logger)
+	require.NoError(t, err)
+	webhooks, err := webhook.NewService(conf, logger, inmemorydb.NewInmemortDBService(conf, logger), newTestFeed(), registry)
+	require.NoError(t, err)
+
+	h := &handler{abiRegistry: registry, webhooks: webhooks, adminToken: "t0ken"}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/webhooks", h.RegisterWebhook).Methods("POST")
+	router.HandleFunc("/v1/webhooks", h.GetWebhooks).Methods("GET")
+	router.HandleFunc("/v1/webhooks/{id}", h.DeleteWebhook).Methods("DELETE")
+
+	type testCase struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		authorization string
+		status        int
+	}
+	for _, tt := range []testCase{
+		{name: "list without a token", method: http.MethodGet, path: "/v1/webhooks", status: http.StatusUnauthorized},
+		{name: "list with a wrong token", method: http.MethodGet, path: "/v1/webhooks", authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "token without the bearer scheme", method: http.MethodGet, path: "/v1/webhooks", authorization: "t0ken", status: http.StatusUnauthorized},
+		{name: "list with the token", method: http.MethodGet, path: "/v1/webhooks", authorization: "Bearer t0ken", status: http.StatusOK},
+		{name: "delete without a token", method: http.MethodDelete, path: "/v1/webhooks/1", status: http.StatusUnauthorized},
+		{name: "delete with the token", method: http.MethodDelete, path: "/v1/webhooks/1", authorization: "Bearer t0ken", status: http.StatusNotFound},
+		{
+			name: "register without a token", method: http.MethodPost, path: "/v1/webhooks",
+			body: `{"url":"https://hooks.example.com","addresses":["0xdAC17F958D2ee523a2206206994597C13D831ec7"]}`, status: http.StatusUnauthorized,
+		},
+		{
+			name: "register the metadata endpoint", method: http.MethodPost, path: "/v1/webhooks", authorization: "Bearer t0ken",
+			body: `{"url":"http://169.254.169.254/latest","addresses":["0xdAC17F958D2ee523a2206206994597C13D831ec7"]}`, status: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.status, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/logfilter"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait        = 10 * time.Second
+	wsPongWait         = 60 * time.Second
+	wsPingInterval     = wsPongWait * 9 / 10
+	wsSendBufferSize   = 256
+	maxWSSubscriptions = 100 // per connection
+
+	rpcSubscriptionMethod = "eth_subscription"
+)
+
+// wsUpgrader accepts the connections of any origin, as the frontends of other domains subscribe too and the served data is public
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// wsConn is the websocket connection of a json-rpc client, with its subscriptions
+type wsConn struct {
+	handler   *handler
+	conn      *websocket.Conn
+	send      chan interface{} // the messages to write, in order
+	done      chan struct{}    // closed once the connection is closed
+	closeOnce sync.Once
+
+	mu            sync.Mutex
+	subscriptions map[string]func() // the unsubscribe functions, by the subscription id
+	starting      []func()          // the new subscriptions, started once their ids are sent
+}
+
+// WebSocket JSON-RPC API endpoint
+// @Summary Ethereum JSON-RPC over websocket, with subscriptions
+// @Description Serve the json-rpc methods of the /rpc endpoint over a websocket, together with eth_subscribe and eth_unsubscribe. The newHeads subscription pushes the header of each new block once it is stored, and the logs subscription pushes the new logs matching its filter once their block is indexed. Both follow the head only, like go-ethereum: the logs of the blocks indexed behind the head, at startup, by the backfill or by the retries, are not pushed, and are available from eth_getLogs. The logs removed by a chain reorganization are pushed again with removed set
+// @Tags JSON-RPC
+// @Success 101
+// @Router /ws [get]
+
+// ServeWebSocket serves the json-rpc requests and the subscriptions of a websocket client
+func (h *handler) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has responded with the error
+	}
+
+	c := &wsConn{
+		handler:       h,
+		conn:          conn,
+		send:          make(chan interface{}, wsSendBufferSize),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]func()),
+	}
+	go c.writeLoop()
+	c.readLoop(r.Context())
+}
+
+// readLoop answers the requests of the client in order, until the connection is closed
+func (c *wsConn) readLoop(ctx context.Context) {
+	defer c.close()
+
+	c.conn.SetReadLimit(maxRPCBodySize)
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		// a slow request holds the pongs back, so the deadline restarts with each request
+		if err := c.conn.SetReadDeadline(time.Now().Add(wsPongWait)); err != nil {
+			return
+		}
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if answer := c.handler.answerRPC(ctx, message, c.dispatch); answer != nil && !c.queue(answer) {
+			return
+		}
+		c.startSubscriptions()
+	}
+}
+
+// writeLoop writes the queued messages and pings the client, until the connection is closed
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case message := <-c.send:
+			if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+				c.close()
+				return
+			}
+			if err := c.conn.WriteJSON(message); err != nil {
+				c.close()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// dispatch calls the method of a json-rpc request, the subscriptions on the connection and the others the same as /rpc
+func (c *wsConn) dispatch(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "eth_subscribe":
+		return c.subscribe(params)
+
+	case "eth_unsubscribe":
+		var id string
+		if err := parseRPCParams(params, 1, &id); err != nil {
+			return nil, err
+		}
+		return c.unsubscribe(id)
+
+	default:
+		return c.handler.dispatchRPC(ctx, method, params)
+	}
+}
+
+// subscribe creates a newHeads or a logs subscription, the same as go-ethereum. It is started once its id is sent to the client,
+// so no notification comes before the id
+func (c *wsConn) subscribe(params json.RawMessage) (string, error) {
+	var (
+		name     string
+		criteria rpcFilterCriteria
+	)
+	if err := parseRPCParams(params, 1, &name, &criteria); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.subscriptions) >= maxWSSubscriptions {
+		return "", &rpcCallError{code: rpcServerErrorCode, message: fmt.Sprintf("too many subscriptions, at most %d per connection", maxWSSubscriptions)}
+	}
+
+	id := string(rpc.NewID())
+	switch name {
+	case "newHeads":
+		sub := c.handler.feed.SubscribeHeads()
+		c.subscriptions[id] = sub.Unsubscribe
+		c.starting = append(c.starting, func() {
+			go forward(c, id, sub, func(*types.Header) bool { return true })
+		})
+
+	case "logs":
+		query, err := criteria.toFilterQuery()
+		if err != nil {
+			return "", err
+		}
+		if err := validateLogsSubscription(criteria, len(query.Topics)); err != nil {
+			return "", err
+		}
+
+		// the logs catching up behind the head are skipped, the same as the headers of their blocks
+		sub := c.handler.feed.SubscribeLogs()
+		c.subscriptions[id] = sub.Unsubscribe
+		c.starting = append(c.starting, func() {
+			go forward(c, id, sub, func(txLog chainfeed.Log) bool { return txLog.Live && logfilter.Match(query, txLog.Log) })
+		})
+
+	default:
+		return "", &rpcCallError{code: rpcMethodNotFoundCode, message: fmt.Sprintf("no %q subscription in eth namespace", name)}
+	}
+
+	return id, nil
+}
+
+// unsubscribe ends a subscription of the connection
+func (c *wsConn) unsubscribe(id string) (bool, error) {
+	c.mu.Lock()
+	unsubscribe, ok := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+
+	if !ok {
+		return false, &rpcCallError{code: rpcServerErrorCode, message: "subscription not found"}
+	}
+	unsubscribe()
+
+	return true, nil
+}
+
+// startSubscriptions starts the subscriptions created by the last message of the client
+func (c *wsConn) startSubscriptions() {
+	c.mu.Lock()
+	starting := c.starting
+	c.starting = nil
+	c.mu.Unlock()
+
+	for _, start := range starting {
+		start()
+	}
+}
+
+func (c *wsConn) isSubscribed(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.subscriptions[id]
+	return ok
+}
+
+// queue queues a message to be written, waiting while the client is behind. It reports false once the connection is closed
+func (c *wsConn) queue(message interface{}) bool {
+	select {
+	case c.send <- message:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// close closes the connection and ends its subscriptions, once
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for id, unsubscribe := range c.subscriptions {
+			delete(c.subscriptions, id)
+			unsubscribe()
+		}
+	})
+}
+
+// forward pushes the matching items of a feed subscription as the notifications of a subscription, until it ends. A subscription
+// dropped by the feed for falling behind closes the connection, as the client could not tell the missed items otherwise
+func forward[T any](c *wsConn, id string, sub *chainfeed.Subscription[T], match func(T) bool) {
+	for item := range sub.C() {
+		if !match(item) {
+			continue
+		}
+
+		notification := models.RPCNotification{
+			JSONRPC: rpcVersion,
+			Method:  rpcSubscriptionMethod,
+			Params:  models.RPCSubscriptionResult{Subscription: id, Result: item},
+		}
+		if !c.queue(notification) {
+			return
+		}
+	}
+
+	if c.isSubscribed(id) {
+		c.close()
+	}
+}
+
+// validateLogsSubscription checks the filter of a logs subscription, the same as go-ethereum: the bounds are block numbers or latest,
+// which follows the new blocks
+func validateLogsSubscription(criteria rpcFilterCriteria, topics int) error {
+	if topics > maxTopicPositions {
+		return &rpcCallError{code: rpcInvalidParamsCode, message: "exceed max topics"}
+	}
+
+	for _, bound := range []*rpc.BlockNumber{criteria.FromBlock, criteria.ToBlock} {
+		if bound != nil && *bound < 0 && *bound != rpc.LatestBlockNumber {
+			return &rpcCallError{code: rpcInvalidParamsCode, message: "only block numbers and latest are supported by the logs subscription"}
+		}
+	}
+	if criteria.FromBlock != nil && criteria.ToBlock != nil && *criteria.FromBlock >= 0 && *criteria.ToBlock >= 0 && *criteria.FromBlock > *criteria.ToBlock {
+		return &rpcCallError{code: rpcInvalidParamsCode, message: "invalid from and to block combination"}
+	}
+
+	return nil
+}
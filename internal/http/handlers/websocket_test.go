@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/models"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsMessage is either a json-rpc response or a subscription notification
+type wsMessage struct {
+	ID     json.RawMessage  `json:"id"`
+	Result json.RawMessage  `json:"result"`
+	Error  *models.RPCError `json:"error"`
+	Method string           `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// wsLog is the part of a pushed log checked by the tests
+type wsLog struct {
+	Address     common.Address `json:"address"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Removed     bool           `json:"removed"`
+}
+
+// dialWebSocket connects a websocket client to the json-rpc endpoint of a handler serving a feed
+func dialWebSocket(t *testing.T, feed chainfeed.Service) *websocket.Conn {
+	h := &handler{feed: feed}
+	server := httptest.NewServer(http.HandlerFunc(h.ServeWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// callWS sends a json-rpc request and reads the next message, which is its response unless a notification comes first
+func callWS(t *testing.T, conn *websocket.Conn, id int, method string, params ...interface{}) wsMessage {
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}))
+	return readWS(t, conn)
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) wsMessage {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var message wsMessage
+	require.NoError(t, conn.ReadJSON(&message))
+
+	return message
+}
+
+// subscribeWS creates a subscription and gets its id
+func subscribeWS(t *testing.T, conn *websocket.Conn, params ...interface{}) string {
+	response := callWS(t, conn, 1, "eth_subscribe", params...)
+	require.Nil(t, response.Error)
+	var id string
+	require.NoError(t, json.Unmarshal(response.Result, &id))
+
+	return id
+}
+
+func newTestFeed() chainfeed.Service {
+	return chainfeed.NewService(config.Config{}, log.New(os.Stdout, "app", log.LstdFlags))
+}
+
+func TestWebSocketNewHeads(t *testing.T) {
+	feed := newTestFeed()
+	conn := dialWebSocket(t, feed)
+
+	// the heads keep coming while the subscription is created, still its id comes first
+	stop := make(chan struct{})
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for number := int64(1); ; number++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				feed.PublishHead(&types.Header{Number: big.NewInt(number)})
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-published
+	}()
+
+	response := callWS(t, conn, 1, "eth_subscribe", "newHeads")
+	require.Empty(t, response.Method, "the id of the subscription comes before its first notification")
+	require.Nil(t, response.Error)
+	var id string
+	require.NoError(t, json.Unmarshal(response.Result, &id))
+
+	notification := readWS(t, conn)
+	assert.Equal(t, rpcSubscriptionMethod, notification.Method)
+	assert.Equal(t, id, notification.Params.Subscription)
+	var header struct {
+		Number *hexutil.Big `json:"number"`
+	}
+	require.NoError(t, json.Unmarshal(notification.Params.Result, &header))
+	assert.NotNil(t, header.Number)
+}
+
+func TestWebSocketLogs(t *testing.T) {
+	feed := newTestFeed()
+	conn := dialWebSocket(t, feed)
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	other := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+	id := subscribeWS(t, conn, "logs", map[string]interface{}{"address": token, "topics": []interface{}{transfer}})
+
+	feed.PublishLogs([]types.Log{{Address: other, BlockNumber: 10, Topics: []common.Hash{transfer}}}, true)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10}}, true)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 4, Topics: []common.Hash{transfer}}}, false)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10, Topics: []common.Hash{transfer}}}, true)
+	feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 10, Topics: []common.Hash{transfer}, Removed: true}}, true)
+
+	var pushed []wsLog
+	for range 2 {
+		notification := readWS(t, conn)
+		require.Equal(t, id, notification.Params.Subscription)
+		assert.NotContains(t, string(notification.Params.Result), "Sequence", "the logs are pushed in the format of go-ethereum")
+		var txLog wsLog
+		require.NoError(t, json.Unmarshal(notification.Params.Result, &txLog))
+		pushed = append(pushed, txLog)
+	}
+	assert.Equal(t, []wsLog{
+		{Address: token, BlockNumber: 10},
+		{Address: token, BlockNumber: 10, Removed: true},
+	}, pushed, "only the live logs matching the filter are pushed, then their removal")
+
+	// nothing else was pushed: the next message is the response of the next request
+	response := callWS(t, conn, 2, "eth_unknown")
+	assert.Empty(t, response.Method)
+	assert.Equal(t, json.RawMessage("2"), response.ID)
+}
+
+func TestWebSocketUnsubscribe(t *testing.T) {
+	feed := newTestFeed()
+	conn := dialWebSocket(t, feed)
+
+	id := subscribeWS(t, conn, "newHeads")
+	response := callWS(t, conn, 2, "eth_unsubscribe", id)
+	require.Nil(t, response.Error)
+	assert.Equal(t, json.RawMessage("true"), response.Result)
+
+	feed.PublishHead(&types.Header{Number: big.NewInt(1)})
+	response = callWS(t, conn, 3, "eth_unsubscribe", id)
+	assert.Empty(t, response.Method, "no notification comes after the unsubscription")
+	require.NotNil(t, response.Error, "a subscription is unsubscribed once")
+	assert.Equal(t, rpcServerErrorCode, response.Error.Code)
+}
+
+func TestWebSocketSubscriptionCap(t *testing.T) {
+	conn := dialWebSocket(t, newTestFeed())
+
+	batch := make([]map[string]interface{}, maxWSSubscriptions)
+	for i := range batch {
+		batch[i] = map[string]interface{}{"jsonrpc": "2.0", "id": i, "method": "eth_subscribe", "params": []string{"newHeads"}}
+	}
+	require.NoError(t, conn.WriteJSON(batch))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var responses []wsMessage
+	require.NoError(t, conn.ReadJSON(&responses))
+	require.Len(t, responses, maxWSSubscriptions)
+	for _, response := range responses {
+		require.Nil(t, response.Error)
+	}
+
+	response := callWS(t, conn, maxWSSubscriptions, "eth_subscribe", "newHeads")
+	require.NotNil(t, response.Error)
+	assert.Equal(t, rpcServerErrorCode, response.Error.Code)
+	assert.Contains(t, response.Error.Message, fmt.Sprint(maxWSSubscriptions))
+}
@@ -18,10 +18,34 @@ import (
 // @version 1.0
 // @description API endpoints for Ethereum blockchain tracking
 // @basePath /v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " and the ADMIN_TOKEN, for the webhook api, the abi uploads and the reprocessing of the dead letters
 func SetupRouters(handler handlers.Handler) http.Handler {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/v1/events/{address}", handler.GetEventsByAddress).Methods("GET")
+	router.HandleFunc("/v1/events/{address}/stream", handler.StreamEventsByAddress).Methods("GET")
+	router.HandleFunc("/v1/addresses/{address}/transfers", handler.GetTransfersByAddress).Methods("GET")
+	router.HandleFunc("/v1/tokens/{token}/transfers", handler.GetTransfersByToken).Methods("GET")
+	router.HandleFunc("/v1/addresses/{address}/transactions", handler.GetTransactionsByAddress).Methods("GET")
+	router.HandleFunc("/v1/blocks/{numberOrHash}", handler.GetBlock).Methods("GET")
+	router.HandleFunc("/v1/blocks/{number}/transactions", handler.GetBlockTransactions).Methods("GET")
+	router.HandleFunc("/v1/transactions/{hash}", handler.GetTransaction).Methods("GET")
+	router.HandleFunc("/v1/abis/{address}", handler.RegisterABI).Methods("PUT")
+	router.HandleFunc("/v1/abis/{address}", handler.GetABI).Methods("GET")
+	router.HandleFunc("/v1/status", handler.GetSyncStatus).Methods("GET")
+	router.HandleFunc("/v1/deadletters", handler.GetDeadLetters).Methods("GET")
+	router.HandleFunc("/v1/deadletters/{blockNumber}/reprocess", handler.ReprocessDeadLetters).Methods("POST")
+	router.HandleFunc("/v1/webhooks", handler.RegisterWebhook).Methods("POST")
+	router.HandleFunc("/v1/webhooks", handler.GetWebhooks).Methods("GET")
+	router.HandleFunc("/v1/webhooks/{id}", handler.GetWebhook).Methods("GET")
+	router.HandleFunc("/v1/webhooks/{id}", handler.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/v1/webhooks/{id}/enable", handler.EnableWebhook).Methods("POST")
+	router.HandleFunc("/v1/webhooks/{id}/deliveries", handler.GetWebhookDeliveries).Methods("GET")
+	router.HandleFunc("/rpc", handler.ServeJSONRPC).Methods("POST")
+	router.HandleFunc("/ws", handler.ServeWebSocket).Methods("GET")
 
 	// Serve the Swagger documentation JSON
 	router.HandleFunc("/swagger.json", func(w http.ResponseWriter, r *http.Request) {
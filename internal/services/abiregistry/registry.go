@@ -0,0 +1,263 @@
+/*
+Registry of the contract ABIs, to decode the events of the known contracts.
+
+An ABI is uploaded per contract address through the API, or loaded on startup from ABI_DIRECTORY where each file is the json ABI of a
+contract named by its address, e.g. 0x388C818CA8B9251b393131C08a736A67ccB19297.json. The uploaded ABIs are written to the directory too,
+if configured, so they survive restarts.
+*/
+package abiregistry
+
+import (
+	"bytes"
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/pkg/customerror"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+const abiFileExtension = ".json"
+
+type Service interface {
+	Register(ctx context.Context, address common.Address, abiJSON []byte) error
+	GetABI(ctx context.Context, address common.Address) ([]byte, error)
+	DecodeLog(txLog types.Log) (string, map[string]interface{}, bool)
+}
+
+type registry struct {
+	config config.Config
+	logger *log.Logger
+
+	mu   sync.RWMutex
+	abis map[common.Address]contractABI
+}
+
+type contractABI struct {
+	parsed abi.ABI
+	raw    []byte
+}
+
+// NewRegistry creates the registry, with the ABIs of the configured directory
+func NewRegistry(config config.Config, logger *log.Logger) (Service, error) {
+	r := &registry{
+		config: config,
+		logger: logger,
+		abis:   make(map[common.Address]contractABI),
+	}
+
+	if err := r.loadDirectory(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Register parses and stores the json ABI of a contract, replacing the previous one. A new contract is rejected once
+// ABI_MAX_CONTRACTS contracts are registered
+func (r *registry) Register(ctx context.Context, address common.Address, abiJSON []byte) error {
+	parsed, err := abi.JSON(bytes.NewReader(abiJSON))
+	if err != nil {
+		return customerror.NewInvalidInputError("Invalid input: abi is not a valid json abi", err)
+	}
+
+	// locked across the write of the file, so the concurrent uploads do not exceed the cap
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.abis[address]; !ok && r.config.ABIConf.MaxContracts > 0 && len(r.abis) >= r.config.ABIConf.MaxContracts {
+		return customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: the abis of %d contracts are registered already", len(r.abis)), nil)
+	}
+
+	if directory := r.config.ABIConf.Directory; directory != "" {
+		if err := writeFileAtomically(filepath.Join(directory, address.Hex()+abiFileExtension), abiJSON); err != nil {
+			return customerror.NewStorageError("", errors.Wrapf(err, "cannot write the abi of contract %s", address.Hex()))
+		}
+	}
+
+	r.abis[address] = contractABI{parsed: parsed, raw: bytes.Clone(abiJSON)}
+
+	return nil
+}
+
+// GetABI gets the json ABI of a contract, as it was registered
+func (r *registry) GetABI(ctx context.Context, address common.Address) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contract, ok := r.abis[address]
+	if !ok {
+		return nil, customerror.NewNotFoundError("abi does not exist", fmt.Errorf("abi of contract %s is not registered", address.Hex()))
+	}
+
+	return bytes.Clone(contract.raw), nil
+}
+
+// DecodeLog decodes a log by the ABI of its contract. It returns the name of the event and its arguments by name,
+// and false when the ABI of the contract is unknown or does not match the log
+func (r *registry) DecodeLog(txLog types.Log) (string, map[string]interface{}, bool) {
+	if len(txLog.Topics) == 0 {
+		return "", nil, false // anonymous events cannot be identified
+	}
+
+	r.mu.RLock()
+	contract, ok := r.abis[txLog.Address]
+	r.mu.RUnlock()
+	if !ok {
+		return "", nil, false
+	}
+
+	event, err := contract.parsed.EventByID(txLog.Topics[0])
+	if err != nil {
+		return "", nil, false
+	}
+
+	// the unnamed arguments are named by their position, so they do not collide
+	inputs := make(abi.Arguments, len(event.Inputs))
+	indexed := make(abi.Arguments, 0)
+	for i, input := range event.Inputs {
+		if input.Name == "" {
+			input.Name = fmt.Sprintf("arg%d", i)
+		}
+		inputs[i] = input
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+
+	args := make(map[string]interface{})
+	if err := inputs.UnpackIntoMap(args, txLog.Data); err != nil {
+		return "", nil, false
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, txLog.Topics[1:]); err != nil {
+		return "", nil, false
+	}
+
+	for name, value := range args {
+		args[name] = jsonValue(value)
+	}
+
+	return event.Name, args, true
+}
+
+// loadDirectory registers the ABIs of the configured directory. A missing directory is created
+func (r *registry) loadDirectory() error {
+	directory := r.config.ABIConf.Directory
+	if directory == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot create the abi directory %s", directory))
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot read the abi directory %s", directory))
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), abiFileExtension)
+		if entry.IsDir() || name == entry.Name() || !common.IsHexAddress(name) {
+			continue
+		}
+
+		abiJSON, err := os.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return customerror.NewStorageError("", errors.Wrapf(err, "cannot read the abi file %s", entry.Name()))
+		}
+
+		parsed, err := abi.JSON(bytes.NewReader(abiJSON))
+		if err != nil {
+			r.logger.Printf("abi file %s is not a valid json abi, skipped: %v", entry.Name(), err)
+			continue
+		}
+		r.abis[common.HexToAddress(name)] = contractABI{parsed: parsed, raw: abiJSON}
+	}
+	r.logger.Printf("%d abis are loaded from %s", len(r.abis), directory)
+
+	return nil
+}
+
+// writeFileAtomically writes a file through a temporary file renamed over it, so a crash never leaves a truncated file
+func writeFileAtomically(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // a no-op once renamed
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// jsonValue converts a decoded argument to a json friendly value: the big numbers to decimal strings, as json numbers
+// lose their precision, the bytes to hex, and the tuples to objects by the names of their components
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		raw := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(raw), rv)
+		return hexutil.Encode(raw)
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return values
+	case rv.Kind() == reflect.Struct:
+		// a tuple is decoded as a struct, whose fields are tagged by the names of the components
+		values := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			values[name] = jsonValue(rv.Field(i).Interface())
+		}
+		return values
+	}
+
+	return value
+}
@@ -0,0 +1,158 @@
+package abiregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const transferABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+func TestDecodeLog(t *testing.T) {
+	conf := config.Config{ABIConf: config.ABIConf{Directory: t.TempDir()}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	ctx := context.Background()
+
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	from := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	to := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5")
+	txLog := types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.BigToHash(new(big.Int).Lsh(big.NewInt(1), 70)).Bytes(),
+	}
+
+	registry, err := NewRegistry(conf, logger)
+	require.NoError(t, err)
+	_, _, ok := registry.DecodeLog(txLog)
+	assert.False(t, ok, "abi of the contract is not registered yet")
+
+	assert.Error(t, registry.Register(ctx, token, []byte(`{"not":"an abi"`)))
+	require.NoError(t, registry.Register(ctx, token, []byte(transferABI)))
+
+	// the uploaded abi is loaded again from the directory
+	reloaded, err := NewRegistry(conf, logger)
+	require.NoError(t, err)
+
+	name, args, ok := reloaded.DecodeLog(txLog)
+	require.True(t, ok)
+	assert.Equal(t, "Transfer", name)
+	assert.Equal(t, map[string]interface{}{
+		"from":  from.Hex(),
+		"to":    to.Hex(),
+		"value": "1180591620717411303424",
+	}, args)
+
+	encoded, err := json.Marshal(models.Event{Log: txLog, EventName: name, DecodedArgs: args})
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &fields))
+	assert.Equal(t, "Transfer", fields["eventName"])
+	assert.Equal(t, strings.ToLower(token.Hex()), fields["address"])
+	assert.Contains(t, fields, "decodedArgs")
+
+	// a log of another event of the contract is not decoded
+	txLog.Topics[0] = common.HexToHash("0x01")
+	_, _, ok = reloaded.DecodeLog(txLog)
+	assert.False(t, ok)
+}
+
+func TestRegisterCapped(t *testing.T) {
+	conf := config.Config{ABIConf: config.ABIConf{MaxContracts: 2}}
+	registry, err := NewRegistry(conf, log.New(os.Stdout, "app", log.LstdFlags))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	second := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	require.NoError(t, registry.Register(ctx, first, []byte(transferABI)))
+	require.NoError(t, registry.Register(ctx, second, []byte(transferABI)))
+
+	err = registry.Register(ctx, common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5"), []byte(transferABI))
+	var customErr *customerror.Error
+	require.True(t, errors.As(err, &customErr), "a new contract is rejected above the cap")
+	assert.Equal(t, customerror.ErrCodeInvalidInput, customErr.Code)
+
+	assert.NoError(t, registry.Register(ctx, first, []byte(transferABI)), "a registered contract is replaced")
+}
+
+func TestDecodeTupleAndUnnamedArgs(t *testing.T) {
+	const orderABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"order","type":"tuple","components":[{"name":"maker","type":"address"},{"name":"amount","type":"uint256"}]},{"indexed":false,"name":"","type":"uint256"},{"indexed":false,"name":"","type":"bytes32"}],"name":"Order","type":"event"}]`
+	directory := t.TempDir()
+	conf := config.Config{ABIConf: config.ABIConf{Directory: directory}}
+	ctx := context.Background()
+
+	registry, err := NewRegistry(conf, log.New(os.Stdout, "app", log.LstdFlags))
+	require.NoError(t, err)
+	exchange := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	require.NoError(t, registry.Register(ctx, exchange, []byte(orderABI)))
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary file is left")
+	assert.Equal(t, exchange.Hex()+".json", entries[0].Name())
+
+	parsed, err := abi.JSON(strings.NewReader(orderABI))
+	require.NoError(t, err)
+	event := parsed.Events["Order"]
+	maker := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	order := struct {
+		Maker  common.Address `json:"maker"`
+		Amount *big.Int       `json:"amount"`
+	}{maker, big.NewInt(5)}
+	data, err := event.Inputs.Pack(order, big.NewInt(7), common.HexToHash("0x01"))
+	require.NoError(t, err)
+
+	name, args, ok := registry.DecodeLog(types.Log{Address: exchange, Topics: []common.Hash{event.ID}, Data: data})
+	require.True(t, ok)
+	assert.Equal(t, "Order", name)
+	assert.Equal(t, map[string]interface{}{
+		"order": map[string]interface{}{"maker": maker.Hex(), "amount": "5"},
+		"arg1":  "7",
+		"arg2":  common.HexToHash("0x01").Hex(),
+	}, args)
+}
+
+func TestEventJSON(t *testing.T) {
+	txLog := types.Log{
+		Address:     common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"),
+		Topics:      []common.Hash{common.HexToHash("0x01")},
+		Data:        []byte{1, 2},
+		BlockNumber: 10,
+		TxIndex:     2,
+		Index:       3,
+		Removed:     true,
+	}
+
+	encodedLog, err := json.Marshal(txLog)
+	require.NoError(t, err)
+	encodedEvent, err := json.Marshal(models.Event{Log: txLog})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(encodedLog), string(encodedEvent), "an event not decoded is encoded as its log")
+
+	encodedEvent, err = json.Marshal(models.Event{Log: txLog, EventName: "Transfer", DecodedArgs: map[string]interface{}{"value": "1"}})
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(encodedEvent, &fields))
+	assert.Equal(t, "0xa", fields["blockNumber"])
+	assert.Equal(t, "Transfer", fields["eventName"])
+	assert.Equal(t, map[string]interface{}{"value": "1"}, fields["decodedArgs"])
+}
@@ -0,0 +1,89 @@
+package blockprocessor
+
+import (
+	"context"
+	"sync"
+)
+
+// checkpointTracker tracks the fully processed blocks, which are processed out of order by the workers and the synchronizer,
+// and advances the checkpoint: the last block number up to which every block is fully processed
+type checkpointTracker struct {
+	mu         sync.Mutex
+	windowSize uint64
+	started    bool
+	next       uint64 // the lowest block number which is not processed yet
+	processed  map[uint64]struct{}
+}
+
+func newCheckpointTracker(windowSize int) *checkpointTracker {
+	return &checkpointTracker{
+		windowSize: uint64(windowSize),
+		processed:  make(map[uint64]struct{}),
+	}
+}
+
+// start sets the first block number to be processed. The blocks processed before the start are kept until then
+func (t *checkpointTracker) start(next uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.started = true
+	t.next = next
+	t.advance()
+}
+
+// markProcessed records a fully processed block and returns the checkpoint, if it is advanced
+func (t *checkpointTracker) markProcessed(number uint64) (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.processed[number] = struct{}{}
+	if !t.started {
+		return 0, false
+	}
+
+	return t.advance()
+}
+
+// advance moves the checkpoint forward over the contiguous processed blocks. The caller must hold the lock
+func (t *checkpointTracker) advance() (uint64, bool) {
+	start := t.next
+
+	// a missing block which fell out of the window does not hold the checkpoint back anymore
+	for number := range t.processed {
+		if t.windowSize != 0 && number >= t.next+t.windowSize {
+			t.next = number - t.windowSize + 1
+		}
+	}
+
+	for {
+		if _, ok := t.processed[t.next]; !ok {
+			break
+		}
+		t.next++
+	}
+
+	for number := range t.processed {
+		if number < t.next {
+			delete(t.processed, number)
+		}
+	}
+
+	if t.next == start || t.next == 0 {
+		return 0, false
+	}
+
+	return t.next - 1, true
+}
+
+// markBlockProcessed advances and stores the checkpoint after a block is fully processed
+func (ec *ethClient) markBlockProcessed(ctx context.Context, number uint64) {
+	checkpoint, advanced := ec.checkpoint.markProcessed(number)
+	if !advanced {
+		return
+	}
+
+	if err := ec.db.SetCheckpoint(ctx, checkpoint); err != nil {
+		ec.logger.Printf("failed to store the checkpoint %d: %v", checkpoint, err)
+	}
+}
@@ -0,0 +1,40 @@
+package blockprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointTracker(t *testing.T) {
+	tracker := newCheckpointTracker(5)
+
+	// blocks of the live synchronizer may be processed before the recent blocks are fetched
+	_, advanced := tracker.markProcessed(13)
+	assert.False(t, advanced)
+
+	tracker.start(10)
+
+	type testCase struct {
+		name       string
+		number     uint64
+		checkpoint uint64
+		advanced   bool
+	}
+
+	testcases := []testCase{
+		{name: "out of order block does not advance", number: 12, advanced: false},
+		{name: "first block advances", number: 10, checkpoint: 10, advanced: true},
+		{name: "filling the gap advances over the processed blocks", number: 11, checkpoint: 13, advanced: true},
+		{name: "missing block 14 holds the checkpoint back", number: 15, advanced: false},
+		{name: "missing block 14 falls out of the window", number: 19, checkpoint: 15, advanced: true},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint, advanced := tracker.markProcessed(tt.number)
+			assert.Equal(t, tt.advanced, advanced)
+			assert.Equal(t, tt.checkpoint, checkpoint)
+		})
+	}
+}
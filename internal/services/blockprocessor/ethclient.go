@@ -3,10 +3,15 @@ package blockprocessor
 import (
 	"context"
 	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/models"
 	"ethereum-tracker-app/pkg/customerror"
+	"fmt"
 	"log"
 	"math/big"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -18,59 +23,153 @@ import (
 
 type Service interface {
 	GetBlockNumber(ctx context.Context) (uint64, error)
+	GetChainID(ctx context.Context) (*big.Int, error)
 	GetBlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
 	GetBlockByHash(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetTransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
 	GetTransactionLogs(ctx context.Context, txHash common.Hash) ([]*types.Log, error)
 	SubscribeNewHeadersViaWss(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
-	ExtractEvents(ctx context.Context, txs types.Transactions)
+	GetBlockReceipts(ctx context.Context, block *types.Block) ([]*types.Receipt, error)
+	ExtractEvents(ctx context.Context, block *types.Block) error
 
-	FetchAndStoreRecentBlocks(ctx context.Context, blockTxChan chan types.Transactions) error
-	WokerTransactionProcessor(ctx context.Context, blockTxChan chan types.Transactions, wg *sync.WaitGroup)
+	FetchAndStoreRecentBlocks(ctx context.Context, blockChan chan *types.Block) error
+	WokerTransactionProcessor(ctx context.Context, blockChan chan *types.Block, wg *sync.WaitGroup)
 	SubscribeToNewGeneratedBlocks(ctx context.Context) error
+	PollNewGeneratedBlocks(ctx context.Context) error
+	SyncNewGeneratedBlocks(ctx context.Context) error
+	GetSyncStatus(ctx context.Context) models.SyncStatus
+
+	ProcessRetryQueue(ctx context.Context)
+	GetDeadLetters(ctx context.Context) []models.DeadLetter
+	ReprocessDeadLetters(ctx context.Context, blockNumber uint64) error
 }
 
 type storageService interface {
 	SetBlock(ctx context.Context, block *types.Block) error
-	SetTransactionHash(ctx context.Context, blockNumber uint64, txHashHex string) error
+	SetTransactionHashes(ctx context.Context, blockNumber uint64, txHashesHex []string) error
 	SetLogsByTx(ctx context.Context, txHashHex string, logs []*types.Log) error
 	SetLogByAddress(ctx context.Context, addressHex string, log *types.Log) error
+	SetReceipt(ctx context.Context, receipt *types.Receipt) error
+	GetReceipt(ctx context.Context, txHashHex string) (*types.Receipt, error)
+	SetTransfer(ctx context.Context, transfer *models.Transfer) error
+	SetAddressTransaction(ctx context.Context, transaction *models.AddressTransaction) error
 	GetLogsByAddress(ctx context.Context, addressHex string) ([]types.Log, error)
+	GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	RollbackBlock(ctx context.Context, number uint64) ([]types.Log, error)
+	SetCheckpoint(ctx context.Context, number uint64) error
+	GetCheckpoint(ctx context.Context) (uint64, error)
+	SetBlockTag(ctx context.Context, tag models.BlockTag, number uint64) error
+	SetDeadLetters(ctx context.Context, deadLetters []models.DeadLetter) error
+	GetDeadLetters(ctx context.Context) ([]models.DeadLetter, error)
 }
 
 type ethClient struct {
 	config     config.Config
 	logger     *log.Logger
-	httpClient *ethclient.Client
+	pool       *rpcPool
+	syncMode   string       // the resolved mode of synchronizing new blocks, subscription or polling
+	wsMu       sync.RWMutex // guards the wsClient, which is replaced on resubscription
 	wsClient   *rpc.Client
+	wsURLIndex int // index of the connected wss url
 	db         storageService
+	feed       chainfeed.Service // the live subscribers are notified of the stored data
+	checkpoint *checkpointTracker
+	status     *syncStatus
+	retries    *retryQueue
+	chainID    atomic.Pointer[big.Int] // retrieved from the node on the first use
+
+	tagsRefreshedAt time.Time // of the safe and finalized blocks, only used by the goroutine synchronizing the new heads
+
+	backfillQueue chan blockRange // ranges of missing block numbers to be fetched
 }
 
-func NewEthClient(ctx context.Context, config config.Config, logger *log.Logger, db storageService) (Service, error) {
-	client, err := ethclient.DialContext(ctx, config.EthClientConf.EthereumHttpURL)
+func NewEthClient(ctx context.Context, config config.Config, logger *log.Logger, db storageService, feed chainfeed.Service) (Service, error) {
+	limiter := newRateLimiter(config.EthClientConf.RPCRateLimit, config.EthClientConf.RPCRateBurst)
+	pool, err := newRPCPool(ctx, config.EthClientConf.EthereumHttpURLs, limiter)
 	if err != nil {
-		return nil, customerror.NewConnectionError("", errors.Wrap(err, "cannot connet to http url of ethereum node"))
+		return nil, err
 	}
 
-	rpcClient, err := rpc.DialContext(ctx, config.EthClientConf.EthereumWSSURL)
+	rpcClient, wsURLIndex, syncMode, err := dialWss(ctx, config.EthClientConf, logger)
 	if err != nil {
-		return nil, customerror.NewConnectionError("", errors.Wrap(err, "cannot connet to wss url of ethereum node"))
+		return nil, err
 	}
 
 	ethClient := &ethClient{
 		config:     config,
 		logger:     logger,
-		httpClient: client,
+		pool:       pool,
+		syncMode:   syncMode,
 		wsClient:   rpcClient,
+		wsURLIndex: wsURLIndex,
 		db:         db,
+		feed:       feed,
+		checkpoint: newCheckpointTracker(config.EthClientConf.NumberOfRecentBlocks),
+		status:     newSyncStatus(),
+		retries:    newRetryQueue(config.EthClientConf.RetryMaxAttempts, config.EthClientConf.RetryBaseBackoff),
+
+		backfillQueue: make(chan blockRange, max(config.EthClientConf.NumberOfRecentBlocks, minBackfillQueueSize)),
 	}
+	ethClient.restoreDeadLetters(ctx)
 
 	return ethClient, nil
 }
 
+// dialWss connects to the first reachable wss url of the node, unless the new blocks are synchronized by polling. In the auto mode,
+// polling is selected when no wss url is configured or none of them can be dialed
+func dialWss(ctx context.Context, ethClientConf config.EthClientConf, logger *log.Logger) (*rpc.Client, int, string, error) {
+	switch ethClientConf.SyncMode {
+	case config.SyncModePolling:
+		return nil, 0, config.SyncModePolling, nil
+	case config.SyncModeSubscription:
+		rpcClient, index, err := dialNextWss(ctx, ethClientConf.EthereumWSSURLs, 0)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		return rpcClient, index, config.SyncModeSubscription, nil
+	case config.SyncModeAuto:
+		if len(ethClientConf.EthereumWSSURLs) == 0 {
+			logger.Println("no wss url of ethereum node, new blocks are synchronized by polling")
+			return nil, 0, config.SyncModePolling, nil
+		}
+
+		rpcClient, index, err := dialNextWss(ctx, ethClientConf.EthereumWSSURLs, 0)
+		if err != nil {
+			logger.Printf("cannot connect to wss url of ethereum node, new blocks are synchronized by polling: %v", err)
+			return nil, 0, config.SyncModePolling, nil
+		}
+		return rpcClient, index, config.SyncModeSubscription, nil
+	default:
+		return nil, 0, "", customerror.New(customerror.ErrCodeInvalidInput, fmt.Sprintf("unknown sync mode %q", ethClientConf.SyncMode), customerror.ErrInvalidInput)
+	}
+}
+
+// dialNextWss dials the wss urls in turn, starting from an index, and returns the first connected one with its index
+func dialNextWss(ctx context.Context, urls []string, start int) (*rpc.Client, int, error) {
+	if len(urls) == 0 {
+		return nil, 0, customerror.NewConnectionError("no wss url of ethereum node is configured", nil)
+	}
+
+	var err error
+	for i := range urls {
+		index := (start + i) % len(urls)
+
+		var rpcClient *rpc.Client
+		rpcClient, err = rpc.DialContext(ctx, urls[index])
+		if err == nil {
+			return rpcClient, index, nil
+		}
+		err = errors.Wrapf(err, "cannot connet to wss url %s of ethereum node", redactURL(urls[index]))
+	}
+
+	return nil, 0, customerror.NewConnectionError("", err)
+}
+
 // GetBlockNumber retrieves the most recent block's number from the Ethereum blockchain
 func (ec *ethClient) GetBlockNumber(ctx context.Context) (uint64, error) {
-	latestBlock, err := ec.httpClient.BlockNumber(ctx)
+	latestBlock, err := callPool(ctx, ec.pool, func(client *ethclient.Client) (uint64, error) {
+		return client.BlockNumber(ctx)
+	})
 	if err != nil {
 		return 0, customerror.NewOnChainDataRetrievalError("", errors.Wrap(err, "cannot get the latest block number of the blockchain"))
 	}
@@ -80,22 +179,35 @@ func (ec *ethClient) GetBlockNumber(ctx context.Context) (uint64, error) {
 
 // GetBlockByNumber retrieves a block associated with a specific block number
 func (ec *ethClient) GetBlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
-	return ec.httpClient.BlockByNumber(ctx, number)
+	return callPool(ctx, ec.pool, func(client *ethclient.Client) (*types.Block, error) {
+		return client.BlockByNumber(ctx, number)
+	})
 }
 
 // GetTransactionByHash retrieves a transaction by transaction-hash
 func (ec *ethClient) GetTransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
-	return ec.httpClient.TransactionByHash(ctx, hash)
+	var isPending bool
+	tx, err := callPool(ctx, ec.pool, func(client *ethclient.Client) (*types.Transaction, error) {
+		tx, pending, err := client.TransactionByHash(ctx, hash)
+		isPending = pending
+		return tx, err
+	})
+
+	return tx, isPending, err
 }
 
 // GetLogs retrieves logs (events) with specific filters, in this task logs of an address
 func (ec *ethClient) GetLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
-	return ec.httpClient.FilterLogs(ctx, query)
+	return callPool(ctx, ec.pool, func(client *ethclient.Client) ([]types.Log, error) {
+		return client.FilterLogs(ctx, query)
+	})
 }
 
 // GetTransactionReceipt retrieves the receipt of a transaction, which contains the logs of the transaction as well
 func (ec *ethClient) GetTransactionLogs(ctx context.Context, txHash common.Hash) ([]*types.Log, error) {
-	receiptOfTx, err := ec.httpClient.TransactionReceipt(ctx, txHash)
+	receiptOfTx, err := callPool(ctx, ec.pool, func(client *ethclient.Client) (*types.Receipt, error) {
+		return client.TransactionReceipt(ctx, txHash)
+	})
 	if err != nil {
 		return nil, customerror.NewLogRetrievalError("", errors.Wrapf(err, "cannot get the logs of the transaction of hash %v", txHash))
 	}
@@ -105,15 +217,22 @@ func (ec *ethClient) GetTransactionLogs(ctx context.Context, txHash common.Hash)
 
 // SubscribeNewBlocks retrieves new header through http url
 func (ec *ethClient) SubscribeNewBlocks(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
-	return ec.httpClient.SubscribeNewHead(ctx, ch)
+	return callPool(ctx, ec.pool, func(client *ethclient.Client) (ethereum.Subscription, error) {
+		return client.SubscribeNewHead(ctx, ch)
+	})
 }
 
 // SubscribeNewHeadersViaWss retrieves new header through wss API, by which the block-number of newly generated blocks can be retrieved
 func (ec *ethClient) SubscribeNewHeadersViaWss(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	ec.wsMu.RLock()
+	defer ec.wsMu.RUnlock()
+
 	return ec.wsClient.EthSubscribe(ctx, ch, "newHeads")
 }
 
 // GetBlockByHash retrieves a block by block-hash
 func (ec *ethClient) GetBlockByHash(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
-	return ec.httpClient.BlockByHash(ctx, blockHash)
+	return callPool(ctx, ec.pool, func(client *ethclient.Client) (*types.Block, error) {
+		return client.BlockByHash(ctx, blockHash)
+	})
 }
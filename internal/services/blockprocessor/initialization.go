@@ -3,6 +3,7 @@ package blockprocessor
 import (
 	"context"
 	"ethereum-tracker-app/pkg/customerror"
+	"ethereum-tracker-app/pkg/tokentransfer"
 	"math/big"
 	"sync"
 
@@ -10,83 +11,193 @@ import (
 	"github.com/pkg/errors"
 )
 
-// FetchAndStoreRecentBlocks retrieves blocks from node and store the data in storage
-func (ec *ethClient) FetchAndStoreRecentBlocks(ctx context.Context, blockTxChan chan types.Transactions) error {
+// FetchAndStoreRecentBlocks retrieves blocks from node and store the data in storage. It resumes from the stored checkpoint,
+// so only the missing blocks between the checkpoint and the latest block are fetched, capped at the window of the recent blocks
+func (ec *ethClient) FetchAndStoreRecentBlocks(ctx context.Context, blockChan chan *types.Block) error {
+	// not closing the chanels is a common cause of the goroutine leak as they never stop
+	defer close(blockChan)
+	ctx = withBackfillPriority(ctx)
+
 	latestBlock, err := ec.GetBlockNumber(ctx)
 	if err != nil {
 		return customerror.NewBlockRetrievalError("", errors.Wrap(err, "cannot fetch the most latest block number"))
 	}
 
-	for i := uint64(0); i < uint64(ec.config.EthClientConf.NumberOfRecentBlocks); i++ {
+	fromBlock := uint64(1)
+	if windowSize := uint64(ec.config.EthClientConf.NumberOfRecentBlocks); latestBlock >= windowSize {
+		fromBlock = latestBlock - windowSize + 1
+	}
+	if checkpoint, err := ec.db.GetCheckpoint(ctx); err == nil && checkpoint >= fromBlock {
+		ec.logger.Printf("resuming from the checkpoint block %d", checkpoint)
+		fromBlock = checkpoint + 1
+	}
+	ec.checkpoint.start(fromBlock)
+
+	for blockNumber := latestBlock; blockNumber >= fromBlock && blockNumber != 0; blockNumber-- {
 		select {
 		case <-ctx.Done():
-			close(blockTxChan)
 			ec.logger.Println("Context cancelled, stopping FetchAndStoreRecentBlocks processor")
 			return nil
 		default:
-			blockNumber := latestBlock - i
-			if blockNumber == 0 {
-				break
-			}
 			ec.logger.Printf("block %d recieved", blockNumber)
 
 			block, err := ec.GetBlockByNumber(ctx, big.NewInt(int64(blockNumber)))
 			if err != nil {
-				ec.logger.Printf("cannot retreive block %d", blockNumber)
+				ec.enqueueFailedFetch(blockNumber, err)
 				continue
 			}
 
-			if setErr := ec.db.SetBlock(ctx, block); setErr != nil {
-				ec.logger.Printf("cannot set the block %d", blockNumber)
+			if setErr := ec.storeBlock(ctx, block); setErr != nil {
+				ec.logger.Printf("cannot set the block %d: %v", blockNumber, setErr)
 			}
 
-			blockTxChan <- block.Transactions()
+			blockChan <- block
 		}
 	}
 
-	// not closing the chanels is a common cause of the goroutine leak as they never stop
-	close(blockTxChan)
-
 	return nil
 }
 
+// storeBlock stores a block and the hashes of all its transactions, in their order in the block
+func (ec *ethClient) storeBlock(ctx context.Context, block *types.Block) error {
+	if err := ec.db.SetBlock(ctx, block); err != nil {
+		return err
+	}
+
+	txHashes := make([]string, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txHashes[i] = tx.Hash().Hex()
+	}
+
+	return ec.db.SetTransactionHashes(ctx, block.NumberU64(), txHashes)
+}
+
 // WokerTransactionProcessor is a worker to process the tranactions of a block
-func (ec *ethClient) WokerTransactionProcessor(ctx context.Context, blockTxChan chan types.Transactions, wg *sync.WaitGroup) {
+func (ec *ethClient) WokerTransactionProcessor(ctx context.Context, blockChan chan *types.Block, wg *sync.WaitGroup) {
+	ctx = withBackfillPriority(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			ec.logger.Println("Context cancelled, stopping transaction processor")
 			return
-		case txs, ok := <-blockTxChan:
+		case block, ok := <-blockChan:
 			if !ok {
 				// Channel closed, exit the loop
-				ec.logger.Println("blockTxChan closed, stopping transaction processor")
+				ec.logger.Println("blockChan closed, stopping transaction processor")
 				return
 			}
 
-			ec.ExtractEvents(ctx, txs)
+			if err := ec.ExtractEvents(ctx, block); err != nil {
+				ec.logger.Printf("block %d is not fully processed: %v", block.NumberU64(), err)
+				continue
+			}
+			ec.markBlockProcessed(ctx, block.NumberU64())
 		}
 	}
 }
 
-// ExtractEvents gets the events (logs) of transactions in a block
-func (ec *ethClient) ExtractEvents(ctx context.Context, txs types.Transactions) {
-	for _, tx := range txs {
-		logs, err := ec.GetTransactionLogs(ctx, tx.Hash())
-		if err != nil {
-			ec.logger.Printf("failed to get logs of the transaction of hash %v \n", tx.Hash())
+// ExtractEvents gets the events (logs) of transactions in a block, from the receipts of the block. The transactions whose
+// receipts cannot be retrieved are enqueued to be retried
+func (ec *ethClient) ExtractEvents(ctx context.Context, block *types.Block) error {
+	// the receipts which are retrieved are processed, even if some others are failed
+	receipts, receiptsErr := ec.GetBlockReceipts(ctx, block)
+	ec.storeReceiptLogs(ctx, block, receipts)
+
+	if receiptsErr != nil {
+		ec.retries.enqueue(block, failedTransactions(block.Transactions(), receipts), receiptsErr)
+	}
+
+	return receiptsErr
+}
+
+// storeReceiptLogs stores the retrieved receipts of a block, their transactions by the sender and the recipient, their logs and
+// the token transfers of the logs. The stored logs are published to the subscribers, as live unless the block is behind the head. A receipt is stored after its logs and
+// its transaction, so the receipts already stored for the block, e.g. of a block fetched again after a restart, are skipped
+func (ec *ethClient) storeReceiptLogs(ctx context.Context, block *types.Block, receipts []*types.Receipt) {
+	signer, signerErr := ec.blockSigner(ctx, block)
+	if signerErr != nil {
+		ec.logger.Printf("transactions of block %d are not indexed by address: %v", block.NumberU64(), signerErr)
+	}
+
+	stored := make([]types.Log, 0)
+	for _, receipt := range receipts {
+		if receipt == nil {
+			continue
+		}
+		if storedReceipt, err := ec.db.GetReceipt(ctx, receipt.TxHash.Hex()); err == nil && storedReceipt.BlockHash == block.Hash() {
+			continue
 		}
 
-		if len(logs) != 0 {
-			if setLogErr := ec.db.SetLogsByTx(ctx, tx.Hash().Hex(), logs); setLogErr != nil {
-				ec.logger.Printf("failed to store logs of transaction %v \n", tx.Hash())
-			}
+		if signerErr == nil {
+			ec.storeAddressTransaction(ctx, signer, block, receipt)
+		}
+		ec.storeLogs(ctx, receipt)
+		stored = append(stored, derefLogs(receipt.Logs)...)
 
-			for _, txLog := range logs {
-				if logAdrErr := ec.db.SetLogByAddress(ctx, txLog.Address.Hex(), txLog); logAdrErr != nil {
-					ec.logger.Printf("faled to store log %v in transaction %v in block %d related to address %v  \n", txLog, tx.Hash(), txLog.BlockNumber, txLog.Address)
-				}
+		if setReceiptErr := ec.db.SetReceipt(ctx, receipt); setReceiptErr != nil {
+			ec.logger.Printf("failed to store receipt of transaction %v \n", receipt.TxHash)
+		}
+	}
+
+	if len(stored) != 0 {
+		ec.feed.PublishLogs(stored, !isBackfill(ctx))
+	}
+}
+
+// storeLogs stores the logs of a receipt, by the transaction and by the address, and the token transfers of the logs
+func (ec *ethClient) storeLogs(ctx context.Context, receipt *types.Receipt) {
+	if len(receipt.Logs) == 0 {
+		return
+	}
+
+	if setLogErr := ec.db.SetLogsByTx(ctx, receipt.TxHash.Hex(), receipt.Logs); setLogErr != nil {
+		ec.logger.Printf("failed to store logs of transaction %v \n", receipt.TxHash)
+	}
+
+	for _, txLog := range receipt.Logs {
+		if logAdrErr := ec.db.SetLogByAddress(ctx, txLog.Address.Hex(), txLog); logAdrErr != nil {
+			ec.logger.Printf("faled to store log %v in transaction %v in block %d related to address %v  \n", txLog, receipt.TxHash, txLog.BlockNumber, txLog.Address)
+		}
+
+		transfers := tokentransfer.Parse(*txLog)
+		for i := range transfers {
+			if setTransferErr := ec.db.SetTransfer(ctx, &transfers[i]); setTransferErr != nil {
+				ec.logger.Printf("failed to store token transfer of log %d in transaction %v \n", txLog.Index, receipt.TxHash)
 			}
 		}
 	}
 }
+
+func derefLogs(logs []*types.Log) []types.Log {
+	values := make([]types.Log, len(logs))
+	for i, txLog := range logs {
+		values[i] = *txLog
+	}
+
+	return values
+}
+
+// storeAddressTransaction stores the transaction of a receipt, indexed by its sender and its recipient
+func (ec *ethClient) storeAddressTransaction(ctx context.Context, signer types.Signer, block *types.Block, receipt *types.Receipt) {
+	transaction, err := addressTransaction(signer, block, receipt)
+	if err != nil {
+		ec.logger.Printf("failed to index transaction %v by address: %v \n", receipt.TxHash, err)
+		return
+	}
+
+	if setTxErr := ec.db.SetAddressTransaction(ctx, transaction); setTxErr != nil {
+		ec.logger.Printf("failed to store transaction %v by address \n", receipt.TxHash)
+	}
+}
+
+// failedTransactions gets the transactions whose receipts are not retrieved, the receipts are in the order of the transactions
+func failedTransactions(txs types.Transactions, receipts []*types.Receipt) types.Transactions {
+	failed := make(types.Transactions, 0)
+	for i, tx := range txs {
+		if i >= len(receipts) || receipts[i] == nil {
+			failed = append(failed, tx)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,77 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreReceiptLogsAgain(t *testing.T) {
+	ctx := context.Background()
+	ec, db := newTestEthClient(t, newFakeNode(), config.Config{StreamConf: config.StreamConf{SubscriptionBufferSize: 16}})
+	chainID := big.NewInt(1337)
+	ec.chainID.Store(chainID)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	recipient := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5")
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	tx := types.MustSignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID: chainID, To: &token, Gas: 60000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2),
+	})
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(1)}).WithBody(types.Body{Transactions: types.Transactions{tx}})
+	txLog := &types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+			common.BytesToHash(sender.Bytes()),
+			common.BytesToHash(recipient.Bytes()),
+		},
+		Data:        common.BigToHash(big.NewInt(1000)).Bytes(),
+		BlockNumber: 1,
+		BlockHash:   block.Hash(),
+		TxHash:      tx.Hash(),
+	}
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockHash: block.Hash(), BlockNumber: big.NewInt(1), Logs: []*types.Log{txLog}}
+
+	sub := ec.feed.SubscribeLogs()
+	defer sub.Unsubscribe()
+
+	// the block is fetched again after a restart, as it was in flight
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ec.storeBlock(ctx, block))
+		ec.storeReceiptLogs(ctx, block, []*types.Receipt{receipt})
+	}
+
+	assert.Len(t, sub.C(), 1, "the logs are published once")
+	assert.True(t, (<-sub.C()).Live, "the logs of the head are live")
+
+	logs, err := db.GetLogsByAddress(ctx, token.Hex())
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+	transfers, err := db.GetTransfersByAddress(ctx, recipient.Hex())
+	require.NoError(t, err)
+	assert.Len(t, transfers, 1)
+	transactions, err := db.GetTransactionsByAddress(ctx, sender.Hex())
+	require.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	_, err = db.GetReceipt(ctx, tx.Hash().Hex())
+	assert.NoError(t, err, "the receipt of a block stored again is kept")
+
+	behind := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), BaseFee: big.NewInt(1)}).WithBody(types.Body{Transactions: types.Transactions{tx}})
+	behindLog := *txLog
+	behindLog.BlockNumber, behindLog.BlockHash = 2, behind.Hash()
+	require.NoError(t, ec.storeBlock(ctx, behind))
+	ec.storeReceiptLogs(withBackfillPriority(ctx), behind, []*types.Receipt{{
+		Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockHash: behind.Hash(), BlockNumber: big.NewInt(2), Logs: []*types.Log{&behindLog},
+	}})
+	assert.False(t, (<-sub.C()).Live, "the logs of the backfilled blocks are catching up")
+}
@@ -0,0 +1,209 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	limitExceededCode    = -32005 // json-rpc error code of exceeding the request rate of the provider
+	minRateLimitBackoff  = time.Second
+	maxRateLimitBackoff  = 30 * time.Second
+	lowPriorityYieldTime = 10 * time.Millisecond
+)
+
+type priorityKey struct{}
+
+// withBackfillPriority marks the outbound requests of a context as backfill traffic, which yields to the live ingestion
+func withBackfillPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, true)
+}
+
+func isBackfill(ctx context.Context) bool {
+	backfill, _ := ctx.Value(priorityKey{}).(bool)
+	return backfill
+}
+
+// rateLimiter is a token bucket limiting the outbound requests to the node, and lets the live ingestion go first while the
+// backfill traffic waits. The rate limit responses of the provider pause only the endpoint replying them, see rateLimitPause
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second, zero disables the limiter
+	burst       float64
+	tokens      float64
+	lastRefill  time.Time
+	liveWaiting int // live callers waiting for tokens
+
+	granted            uint64
+	throttled          uint64 // granted after waiting
+	rateLimitedReplies uint64
+}
+
+func newRateLimiter(requestsPerSecond, burst int) *rateLimiter {
+	burst = max(burst, 1)
+	return &rateLimiter{
+		rate:       float64(requestsPerSecond),
+		burst:      float64(burst),
+		tokens:     float64(burst),
+		lastRefill: time.Now(),
+	}
+}
+
+// wait blocks until the cost of a request is granted, or the context is cancelled
+func (l *rateLimiter) wait(ctx context.Context, cost int) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	backfill := isBackfill(ctx)
+	if !backfill {
+		l.mu.Lock()
+		l.liveWaiting++
+		l.mu.Unlock()
+
+		defer func() {
+			l.mu.Lock()
+			l.liveWaiting--
+			l.mu.Unlock()
+		}()
+	}
+
+	// a cost above the burst could never be granted
+	tokens := min(float64(cost), l.burst)
+	for throttled := false; ; throttled = true {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+
+		var delay time.Duration
+		switch {
+		case backfill && l.liveWaiting > 0:
+			delay = lowPriorityYieldTime
+		case l.tokens >= tokens:
+			l.tokens -= tokens
+			l.granted++
+			if throttled {
+				l.throttled++
+			}
+			l.mu.Unlock()
+			return nil
+		default:
+			delay = time.Duration((tokens - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// refill adds the tokens earned since the last refill. The caller must hold the lock
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.lastRefill).Seconds()*l.rate)
+	l.lastRefill = now
+}
+
+// observe counts the rate limit replies of the provider
+func (l *rateLimiter) observe(err error) {
+	if !isRateLimited(err) {
+		return
+	}
+
+	l.mu.Lock()
+	l.rateLimitedReplies++
+	l.mu.Unlock()
+}
+
+// rateLimitPause pauses the requests to an endpoint with exponential backoff when it replies with a rate limit error, and resets
+// the backoff on its other replies. The other endpoints of the pool keep serving meanwhile
+type rateLimitPause struct {
+	mu          sync.Mutex
+	pausedUntil time.Time
+	backoff     time.Duration
+}
+
+func (p *rateLimitPause) observe(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !isRateLimited(err) {
+		p.backoff = 0
+		return
+	}
+
+	p.backoff = min(max(p.backoff*2, minRateLimitBackoff), maxRateLimitBackoff)
+	p.pausedUntil = time.Now().Add(p.backoff)
+}
+
+// until gets the end of the pause, if the endpoint is paused
+func (p *rateLimitPause) until(now time.Time) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pausedUntil, now.Before(p.pausedUntil)
+}
+
+// wait blocks until the pause is over, or the context is cancelled
+func (p *rateLimitPause) wait(ctx context.Context) error {
+	pausedUntil, paused := p.until(time.Now())
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(pausedUntil)):
+		return nil
+	}
+}
+
+// budget gets the current usage of the request budget
+func (l *rateLimiter) budget() models.RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.rate > 0 {
+		l.refill(now)
+	}
+
+	return models.RateLimitStatus{
+		RequestsPerSecond:  l.rate,
+		Burst:              l.burst,
+		AvailableTokens:    l.tokens,
+		Granted:            l.granted,
+		Throttled:          l.throttled,
+		RateLimitedReplies: l.rateLimitedReplies,
+	}
+}
+
+// isRateLimited reports whether an error is a rate limit reply of the provider, either http 429 or a json-rpc limit error
+func isRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		message := strings.ToLower(rpcErr.Error())
+		return rpcErr.ErrorCode() == limitExceededCode || strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests")
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+package blockprocessor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonrpcError struct {
+	code    int
+	message string
+}
+
+func (e jsonrpcError) Error() string  { return e.message }
+func (e jsonrpcError) ErrorCode() int { return e.code }
+
+func TestIsRateLimited(t *testing.T) {
+	type testCase struct {
+		name        string
+		err         error
+		rateLimited bool
+	}
+
+	testcases := []testCase{
+		{name: "no error", err: nil, rateLimited: false},
+		{name: "http 429", err: rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, rateLimited: true},
+		{name: "http 503", err: rpc.HTTPError{StatusCode: http.StatusServiceUnavailable}, rateLimited: false},
+		{name: "json-rpc limit exceeded", err: jsonrpcError{code: limitExceededCode, message: "project ID request rate exceeded"}, rateLimited: true},
+		{name: "json-rpc rate limit message", err: jsonrpcError{code: -32000, message: "Rate limit reached"}, rateLimited: true},
+		{name: "other json-rpc error", err: jsonrpcError{code: -32000, message: "header not found"}, rateLimited: false},
+		{name: "other error", err: errors.New("connection refused"), rateLimited: false},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.rateLimited, isRateLimited(tt.err))
+		})
+	}
+}
+
+func TestRateLimiterPriorityAndBackoff(t *testing.T) {
+	limiter := newRateLimiter(1000, 10)
+
+	// backfill traffic waits while a live caller is waiting
+	limiter.liveWaiting = 1
+	ctx, cancel := context.WithTimeout(withBackfillPriority(context.Background()), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.wait(ctx, 1), context.DeadlineExceeded)
+
+	limiter.liveWaiting = 0
+	assert.NoError(t, limiter.wait(withBackfillPriority(context.Background()), 1))
+
+	limiter.observe(rpc.HTTPError{StatusCode: http.StatusTooManyRequests})
+	assert.Equal(t, uint64(1), limiter.budget().RateLimitedReplies)
+
+	// a rate limit reply pauses the endpoint with backoff, until another reply
+	var pause rateLimitPause
+	pause.observe(rpc.HTTPError{StatusCode: http.StatusTooManyRequests})
+	pause.observe(rpc.HTTPError{StatusCode: http.StatusTooManyRequests})
+	pausedUntil, paused := pause.until(time.Now())
+	assert.True(t, paused)
+	assert.WithinDuration(t, time.Now().Add(2*minRateLimitBackoff), pausedUntil, 100*time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pause.wait(ctx), context.DeadlineExceeded)
+
+	pause.observe(nil)
+	assert.Zero(t, pause.backoff)
+}
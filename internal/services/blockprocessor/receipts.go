@@ -0,0 +1,91 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/pkg/customerror"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const methodNotFoundCode = -32601 // json-rpc error code of an unsupported method
+
+// GetBlockReceipts retrieves the receipts of all the transactions of a block, by eth_getBlockReceipts from an endpoint supporting
+// it, otherwise by batched eth_getTransactionReceipt requests. The receipts are in the order of the transactions, and the receipts
+// which cannot be retrieved are nil
+func (ec *ethClient) GetBlockReceipts(ctx context.Context, block *types.Block) ([]*types.Receipt, error) {
+	if len(block.Transactions()) == 0 {
+		return nil, nil
+	}
+
+	receipts, err := callEndpoints(ctx, ec.pool, 1, func(endpoint *rpcEndpoint) bool {
+		return endpoint.blockReceiptsUnsupported.Load()
+	}, func(endpoint *rpcEndpoint) ([]*types.Receipt, error) {
+		receipts, err := endpoint.client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+			ec.logger.Printf("eth_getBlockReceipts is not supported by the node %s, falling back to batched receipt requests", redactURL(endpoint.url))
+			endpoint.blockReceiptsUnsupported.Store(true)
+		}
+		return receipts, err
+	})
+	if err == nil && len(receipts) == len(block.Transactions()) {
+		return receipts, nil
+	}
+
+	var rpcErr rpc.Error
+	if !errors.Is(err, errNoEndpoint) && !(errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode) {
+		ec.logger.Printf("failed to get the receipts of block %d at once, falling back to batched receipt requests: %v", block.NumberU64(), err)
+	}
+
+	return ec.getBatchedReceipts(ctx, block.Transactions())
+}
+
+// getBatchedReceipts retrieves the receipts of transactions by batched json-rpc requests, RECEIPTS_BATCH_SIZE receipts per batch
+func (ec *ethClient) getBatchedReceipts(ctx context.Context, txs types.Transactions) ([]*types.Receipt, error) {
+	batchSize := max(ec.config.EthClientConf.ReceiptsBatchSize, 1)
+	receipts := make([]*types.Receipt, len(txs))
+	failedTxs := 0
+
+	for start := 0; start < len(txs); start += batchSize {
+		end := min(start+batchSize, len(txs))
+
+		batch := make([]rpc.BatchElem, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, rpc.BatchElem{
+				Method: "eth_getTransactionReceipt",
+				Args:   []interface{}{txs[i].Hash()},
+				Result: &receipts[i],
+			})
+		}
+
+		_, err := callPoolWithCost(ctx, ec.pool, len(batch), func(client *ethclient.Client) (struct{}, error) {
+			return struct{}{}, client.Client().BatchCallContext(ctx, batch)
+		})
+		if err != nil {
+			for i := start; i < end; i++ {
+				receipts[i] = nil
+			}
+			failedTxs += end - start
+			ec.logger.Printf("failed to get a batch of %d receipts: %v", end-start, err)
+			continue
+		}
+
+		for i, elem := range batch {
+			if elem.Error != nil || receipts[start+i] == nil {
+				receipts[start+i] = nil
+				failedTxs++
+				ec.logger.Printf("failed to get the receipt of the transaction of hash %v: %v", txs[start+i].Hash(), elem.Error)
+			}
+		}
+	}
+
+	if failedTxs != 0 {
+		return receipts, customerror.NewLogRetrievalError("", errors.Errorf("cannot get the receipts of %d transactions out of %d", failedTxs, len(txs)))
+	}
+
+	return receipts, nil
+}
@@ -0,0 +1,124 @@
+package blockprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"ethereum-tracker-app/cmd/config"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonrpcMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func TestGetBlockReceiptsFallsBackToBatches(t *testing.T) {
+	txs := make(types.Transactions, 5)
+	for i := range txs {
+		txs[i] = types.NewTx(&types.LegacyTx{Nonce: uint64(i)})
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(types.Body{Transactions: txs})
+
+	batchSizes := make([]int, 0)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+
+		var batch []jsonrpcMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			var msg jsonrpcMessage
+			json.Unmarshal(body, &msg)
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist"}}`))
+			return
+		}
+
+		batchSizes = append(batchSizes, len(batch))
+		responses := make([]json.RawMessage, len(batch))
+		for i, msg := range batch {
+			var txHash common.Hash
+			json.Unmarshal(msg.Params[0], &txHash)
+			receipt, _ := json.Marshal(&types.Receipt{TxHash: txHash, Logs: []*types.Log{}})
+			responses[i] = json.RawMessage(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":` + string(receipt) + `}`)
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer node.Close()
+
+	ctx := context.Background()
+	pool, err := newRPCPool(ctx, []string{node.URL}, newRateLimiter(0, 0))
+	assert.NoError(t, err)
+	ec := &ethClient{
+		config: config.Config{EthClientConf: config.EthClientConf{ReceiptsBatchSize: 2}},
+		logger: log.New(os.Stdout, "app", log.LstdFlags),
+		pool:   pool,
+	}
+
+	receipts, err := ec.GetBlockReceipts(ctx, block)
+	assert.NoError(t, err)
+	assert.True(t, pool.endpoints[0].blockReceiptsUnsupported.Load())
+	assert.Equal(t, []int{2, 2, 1}, batchSizes)
+	assert.Len(t, receipts, len(txs))
+	for i, receipt := range receipts {
+		assert.Equal(t, txs[i].Hash(), receipt.TxHash)
+	}
+	assert.Equal(t, uint64(0), pool.health()[0].Failures, "an unsupported method is not a failure of the endpoint")
+}
+
+func TestBlockReceiptsSupportPerEndpoint(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(types.Body{Transactions: types.Transactions{types.NewTx(&types.LegacyTx{})}})
+
+	// newNode serves eth_getBlockReceipts if it is supported, and counts its calls
+	newNode := func(supported bool, calls *atomic.Int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+
+			var batch []jsonrpcMessage
+			if err := json.Unmarshal(body, &batch); err == nil {
+				receipt, _ := json.Marshal(&types.Receipt{TxHash: block.Transactions()[0].Hash(), Logs: []*types.Log{}})
+				w.Write([]byte(`[{"jsonrpc":"2.0","id":` + string(batch[0].ID) + `,"result":` + string(receipt) + `}]`))
+				return
+			}
+
+			var msg jsonrpcMessage
+			json.Unmarshal(body, &msg)
+			calls.Add(1)
+			if !supported {
+				w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist"}}`))
+				return
+			}
+			receipts, _ := json.Marshal([]*types.Receipt{{TxHash: block.Transactions()[0].Hash(), Logs: []*types.Log{}}})
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":` + string(receipts) + `}`))
+		}))
+	}
+	var unsupportedCalls, supportedCalls atomic.Int32
+	unsupported, supported := newNode(false, &unsupportedCalls), newNode(true, &supportedCalls)
+	defer unsupported.Close()
+	defer supported.Close()
+
+	ctx := context.Background()
+	pool, err := newRPCPool(ctx, []string{unsupported.URL, supported.URL}, newRateLimiter(0, 0))
+	assert.NoError(t, err)
+	ec := &ethClient{logger: log.New(os.Stdout, "app", log.LstdFlags), pool: pool}
+
+	for i := 0; i < 3; i++ {
+		receipts, err := ec.GetBlockReceipts(ctx, block)
+		assert.NoError(t, err)
+		assert.Len(t, receipts, 1)
+	}
+	assert.LessOrEqual(t, unsupportedCalls.Load(), int32(1), "the endpoint without eth_getBlockReceipts is asked once")
+	assert.GreaterOrEqual(t, supportedCalls.Load(), int32(2), "the endpoint with eth_getBlockReceipts keeps serving it")
+	assert.False(t, pool.endpoints[1].blockReceiptsUnsupported.Load())
+}
@@ -0,0 +1,89 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/pkg/customerror"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// resolveCanonicalChain detects a chain reorganization by walking the parent hashes of a new block against the stored blocks.
+// The orphaned blocks are rolled back and the canonical blocks which need to be ingested are returned in ascending order
+func (ec *ethClient) resolveCanonicalChain(ctx context.Context, block *types.Block) ([]*types.Block, error) {
+	windowSize := ec.config.EthClientConf.NumberOfRecentBlocks
+	canonicalBlocks := make([]*types.Block, 0, 1)
+	current := block
+	for {
+		stored, err := ec.db.GetBlockByNumber(ctx, current.NumberU64())
+		if err == nil {
+			if stored.Hash() == current.Hash() {
+				break // already ingested, so it is the common ancestor
+			}
+			ec.rollbackBlock(ctx, current.NumberU64())
+		}
+		canonicalBlocks = append(canonicalBlocks, current)
+
+		if current.NumberU64() == 0 || (windowSize != 0 && len(canonicalBlocks) >= windowSize) {
+			break // the reorg is deeper than the window of the recent blocks
+		}
+
+		parent, err := ec.db.GetBlockByNumber(ctx, current.NumberU64()-1)
+		if err != nil || parent.Hash() == current.ParentHash() {
+			break
+		}
+
+		ec.logger.Printf("chain reorganization detected at block %d: stored parent %v, canonical parent %v", current.NumberU64(), parent.Hash(), current.ParentHash())
+		parentHash := current.ParentHash()
+		current, err = ec.GetBlockByHash(ctx, parentHash)
+		if err != nil {
+			return nil, customerror.NewBlockRetrievalError("", errors.Wrapf(err, "cannot get the canonical block of hash %v", parentHash))
+		}
+	}
+
+	// reverse, so the blocks are ingested from the oldest one
+	for i, j := 0, len(canonicalBlocks)-1; i < j; i, j = i+1, j-1 {
+		canonicalBlocks[i], canonicalBlocks[j] = canonicalBlocks[j], canonicalBlocks[i]
+	}
+
+	return canonicalBlocks, nil
+}
+
+// isReorganizedHead reports whether a head replaces the stored chain, i.e. whether its hash, or the hash of its parent, differs from
+// the stored one
+func (ec *ethClient) isReorganizedHead(ctx context.Context, block *types.Block) bool {
+	if stored, err := ec.db.GetBlockByNumber(ctx, block.NumberU64()); err == nil {
+		return stored.Hash() != block.Hash()
+	}
+	if block.NumberU64() == 0 {
+		return false
+	}
+
+	parent, err := ec.db.GetBlockByNumber(ctx, block.NumberU64()-1)
+	return err == nil && parent.Hash() != block.ParentHash()
+}
+
+// rollbackBlocksAbove rolls back the blocks of the old chain above a new head, in case the new chain is shorter
+func (ec *ethClient) rollbackBlocksAbove(ctx context.Context, head uint64) {
+	for number := head + 1; ; number++ {
+		if _, err := ec.db.GetBlockByNumber(ctx, number); err != nil {
+			return
+		}
+		ec.rollbackBlock(ctx, number)
+	}
+}
+
+// rollbackBlock removes an orphaned block and its logs from the datastore, and publishes the removed logs to the live subscribers
+func (ec *ethClient) rollbackBlock(ctx context.Context, number uint64) {
+	removedLogs, err := ec.db.RollbackBlock(ctx, number)
+	if err != nil {
+		ec.logger.Printf("failed to rollback the orphaned block %d: %v", number, err)
+		return
+	}
+
+	ec.logger.Printf("orphaned block %d rolled back, %d logs flagged as removed", number, len(removedLogs))
+	if len(removedLogs) != 0 {
+		// live whatever the traffic detecting the reorganization, as the removed logs may have been pushed live
+		ec.feed.PublishLogs(removedLogs, true)
+	}
+}
@@ -0,0 +1,281 @@
+package blockprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is an ethereum node serving the blocks of a chain without transactions
+type fakeNode struct {
+	mu       sync.Mutex
+	byHash   map[common.Hash]*types.Block
+	byNumber map[uint64]*types.Block // the canonical chain
+	head     uint64
+	failures map[uint64]int // failed eth_getBlockByNumber calls of a block number before it is served
+	tagCalls int            // eth_getBlockByNumber calls of the safe and finalized tags, served the head
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{byHash: make(map[common.Hash]*types.Block), byNumber: make(map[uint64]*types.Block), failures: make(map[uint64]int)}
+}
+
+// setCanonical makes the blocks canonical, and the last one the head
+func (n *fakeNode) setCanonical(blocks ...*types.Block) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, block := range blocks {
+		n.byHash[block.Hash()] = block
+		n.byNumber[block.NumberU64()] = block
+		n.head = block.NumberU64()
+	}
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var msg jsonrpcMessage
+	json.Unmarshal(body, &msg)
+	w.Header().Set("Content-Type", "application/json")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var result interface{}
+	switch msg.Method {
+	case "eth_blockNumber":
+		result = hexutil.Uint64(n.head)
+	case "eth_getBlockByHash":
+		var hash common.Hash
+		json.Unmarshal(msg.Params[0], &hash)
+		result = marshalTestBlock(n.byHash[hash])
+	case "eth_getBlockByNumber":
+		var number hexutil.Uint64
+		if err := json.Unmarshal(msg.Params[0], &number); err != nil {
+			n.tagCalls++
+			number = hexutil.Uint64(n.head)
+		}
+		if n.failures[uint64(number)] > 0 {
+			n.failures[uint64(number)]--
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"error":{"code":-32000,"message":"header not found"}}`))
+			return
+		}
+		result = marshalTestBlock(n.byNumber[uint64(number)])
+	}
+
+	encoded, _ := json.Marshal(result)
+	w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":` + string(encoded) + `}`))
+}
+
+// marshalTestBlock encodes a block without transactions in the json format of the node, nil if it does not exist
+func marshalTestBlock(block *types.Block) map[string]interface{} {
+	if block == nil {
+		return nil
+	}
+
+	encoded, _ := json.Marshal(block.Header())
+	fields := make(map[string]interface{})
+	json.Unmarshal(encoded, &fields)
+	fields["hash"] = block.Hash()
+	fields["transactions"] = []interface{}{}
+	fields["uncles"] = []interface{}{}
+
+	return fields
+}
+
+// newTestChain builds the blocks from a number to another on top of a parent, nil for the genesis. The blocks of different
+// forks differ by their extra data
+func newTestChain(parent *types.Block, from, to uint64, fork string) []*types.Block {
+	blocks := make([]*types.Block, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		header := &types.Header{
+			Number:      new(big.Int).SetUint64(number),
+			Difficulty:  big.NewInt(0),
+			Extra:       []byte(fork),
+			TxHash:      types.EmptyTxsHash,
+			UncleHash:   types.EmptyUncleHash,
+			ReceiptHash: types.EmptyReceiptsHash,
+		}
+		if parent != nil {
+			header.ParentHash = parent.Hash()
+		}
+		parent = types.NewBlockWithHeader(header)
+		blocks = append(blocks, parent)
+	}
+
+	return blocks
+}
+
+// newTestEthClient creates a client of a fake node, storing in memory
+func newTestEthClient(t *testing.T, node *fakeNode, conf config.Config) (*ethClient, inmemorydb.Service) {
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	pool, err := newRPCPool(context.Background(), []string{server.URL}, newRateLimiter(0, 0))
+	require.NoError(t, err)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+
+	return &ethClient{
+		config:        conf,
+		logger:        logger,
+		pool:          pool,
+		db:            db,
+		feed:          chainfeed.NewService(conf, logger),
+		checkpoint:    newCheckpointTracker(conf.EthClientConf.NumberOfRecentBlocks),
+		status:        newSyncStatus(),
+		retries:       newRetryQueue(conf.EthClientConf.RetryMaxAttempts, conf.EthClientConf.RetryBaseBackoff),
+		backfillQueue: make(chan blockRange, 16),
+	}, db
+}
+
+func TestResolveCanonicalChain(t *testing.T) {
+	type testCase struct {
+		name       string
+		windowSize int
+		forkAt     uint64 // first block of the new fork on top of the old chain of blocks 1 to 5, 6 if none
+		want       []uint64
+		rolledBack []uint64
+	}
+
+	testcases := []testCase{
+		{name: "no reorg", forkAt: 6, want: []uint64{6}},
+		{name: "1-deep reorg", forkAt: 5, want: []uint64{5, 6}, rolledBack: []uint64{5}},
+		{name: "N-deep reorg without a window", forkAt: 3, want: []uint64{3, 4, 5, 6}, rolledBack: []uint64{3, 4, 5}},
+		{name: "N-deep reorg in the window", windowSize: 10, forkAt: 2, want: []uint64{2, 3, 4, 5, 6}, rolledBack: []uint64{2, 3, 4, 5}},
+		{name: "reorg deeper than the window", windowSize: 2, forkAt: 3, want: []uint64{5, 6}, rolledBack: []uint64{5}},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			node := newFakeNode()
+			ec, db := newTestEthClient(t, node, config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: tt.windowSize}})
+
+			oldChain := newTestChain(nil, 1, 5, "old")
+			for _, block := range oldChain {
+				require.NoError(t, db.SetBlock(ctx, block))
+			}
+			newChain := newTestChain(oldChain[tt.forkAt-2], tt.forkAt, 6, "new")
+			node.setCanonical(oldChain[:tt.forkAt-1]...)
+			node.setCanonical(newChain...)
+
+			canonicalBlocks, err := ec.resolveCanonicalChain(ctx, newChain[len(newChain)-1])
+			require.NoError(t, err)
+
+			numbers := make([]uint64, len(canonicalBlocks))
+			for i, block := range canonicalBlocks {
+				numbers[i] = block.NumberU64()
+				assert.Equal(t, node.byNumber[block.NumberU64()].Hash(), block.Hash(), "block %d is canonical", block.NumberU64())
+			}
+			assert.Equal(t, tt.want, numbers)
+
+			for _, number := range tt.rolledBack {
+				_, err := db.GetBlockByNumber(ctx, number)
+				assert.Error(t, err, "orphaned block %d is rolled back", number)
+			}
+			for number := uint64(1); number < tt.forkAt; number++ {
+				if tt.windowSize != 0 && number+uint64(tt.windowSize) <= 5 {
+					continue // evicted
+				}
+				stored, err := db.GetBlockByNumber(ctx, number)
+				require.NoError(t, err)
+				assert.Equal(t, oldChain[number-1].Hash(), stored.Hash(), "common block %d is kept", number)
+			}
+		})
+	}
+}
+
+func TestProcessOlderHead(t *testing.T) {
+	type testCase struct {
+		name         string
+		fork         bool // whether the older head is of a new fork replacing the stored blocks from it
+		wantLastSeen uint64
+		rolledBack   []uint64
+	}
+
+	testcases := []testCase{
+		{name: "lagging endpoint", wantLastSeen: 5},
+		{name: "reorg to a shorter chain", fork: true, wantLastSeen: 3, rolledBack: []uint64{4, 5}},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			node := newFakeNode()
+			ec, db := newTestEthClient(t, node, config.Config{})
+
+			chain := newTestChain(nil, 1, 5, "old")
+			for _, block := range chain {
+				require.NoError(t, db.SetBlock(ctx, block))
+			}
+			node.setCanonical(chain...)
+			if tt.fork {
+				node.setCanonical(newTestChain(chain[1], 3, 3, "new")...)
+			} else {
+				node.head = 3
+			}
+
+			ec.status.setLastSeenBlock(5)
+			assert.Equal(t, tt.wantLastSeen, ec.processNewHead(ctx, 3, 5))
+			assert.Equal(t, tt.wantLastSeen, ec.status.lastSeenBlock)
+
+			for _, number := range tt.rolledBack {
+				_, err := db.GetBlockByNumber(ctx, number)
+				assert.Error(t, err, "orphaned block %d is rolled back", number)
+			}
+			for number := uint64(1); number <= 5; number++ {
+				if slices.Contains(tt.rolledBack, number) {
+					continue
+				}
+				stored, err := db.GetBlockByNumber(ctx, number)
+				require.NoError(t, err)
+				assert.Equal(t, node.byNumber[number].Hash(), stored.Hash(), "canonical block %d is kept", number)
+			}
+		})
+	}
+}
+
+func TestBlockTagsRefreshInterval(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeNode()
+	ec, db := newTestEthClient(t, node, config.Config{EthClientConf: config.EthClientConf{BlockTagsRefreshInterval: time.Hour}})
+
+	chain := newTestChain(nil, 1, 3, "canonical")
+	for i, block := range chain {
+		node.setCanonical(block)
+		ec.processNewHead(ctx, block.NumberU64(), uint64(i))
+	}
+
+	assert.Equal(t, 2, node.tagCalls, "the safe and the finalized blocks are refreshed once per interval")
+	safe, err := db.GetBlockTag(ctx, models.BlockTagSafe)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), safe)
+
+	ec.tagsRefreshedAt = time.Now().Add(-time.Hour)
+	node.setCanonical(newTestChain(chain[2], 4, 4, "canonical")...)
+	ec.processNewHead(ctx, 4, 3)
+	assert.Equal(t, 4, node.tagCalls)
+	safe, err = db.GetBlockTag(ctx, models.BlockTagSafe)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), safe)
+}
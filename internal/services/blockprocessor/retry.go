@@ -0,0 +1,353 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	retryPollInterval = time.Second
+	maxRetryBackoff   = time.Hour
+)
+
+// retryKey identifies a retried block by its number and its hash. The hash of a block which could not be fetched is not known
+type retryKey struct {
+	blockNumber uint64
+	blockHash   common.Hash
+}
+
+// retryItem is a block which could not be fetched, without a hash, or a block whose transactions' receipts could not be retrieved
+type retryItem struct {
+	blockNumber uint64
+	blockHash   common.Hash
+	txs         types.Transactions
+	attempts    int
+	nextAttempt time.Time
+	lastError   string
+	failedAt    time.Time
+}
+
+// retryQueue keeps the failed items to be retried with bounded backoff, and the dead letters which exhausted their retries
+type retryQueue struct {
+	mu          sync.Mutex
+	maxAttempts int
+	baseBackoff time.Duration
+	pending     map[retryKey]*retryItem
+	inFlight    map[retryKey]struct{} // taken by due, until their attempt is over
+	deadLetters map[retryKey]*retryItem
+}
+
+func newRetryQueue(maxAttempts int, baseBackoff time.Duration) *retryQueue {
+	return &retryQueue{
+		maxAttempts: maxAttempts,
+		baseBackoff: baseBackoff,
+		pending:     make(map[retryKey]*retryItem),
+		inFlight:    make(map[retryKey]struct{}),
+		deadLetters: make(map[retryKey]*retryItem),
+	}
+}
+
+// enqueue adds the failed transactions of a block to be retried. A block which is already pending keeps its attempts
+func (q *retryQueue) enqueue(block *types.Block, txs types.Transactions, err error) {
+	if len(txs) == 0 {
+		return
+	}
+
+	q.add(block.NumberU64(), block.Hash(), txs, err)
+}
+
+// enqueueFetch adds a block which could not be fetched to be fetched again
+func (q *retryQueue) enqueueFetch(blockNumber uint64, err error) {
+	q.add(blockNumber, common.Hash{}, nil, err)
+}
+
+// add adds an item to be retried, unless it is a dead letter already. A pending item keeps its attempts
+func (q *retryQueue) add(blockNumber uint64, blockHash common.Hash, txs types.Transactions, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := retryKey{blockNumber: blockNumber, blockHash: blockHash}
+	if _, ok := q.deadLetters[key]; ok {
+		return
+	}
+	item, ok := q.pending[key]
+	if !ok {
+		item = &retryItem{blockNumber: blockNumber, blockHash: blockHash}
+		q.pending[key] = item
+	}
+	item.txs = txs
+	item.lastError = err.Error()
+	item.nextAttempt = time.Now().Add(q.backoff(item.attempts))
+}
+
+// due takes the pending items whose backoff is over
+func (q *retryQueue) due() []*retryItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	items := make([]*retryItem, 0)
+	for key, item := range q.pending {
+		if !now.Before(item.nextAttempt) {
+			items = append(items, item)
+			delete(q.pending, key)
+			q.inFlight[key] = struct{}{}
+		}
+	}
+
+	return items
+}
+
+// fail records a failed attempt of an item, which is retried again or moved to the dead letters once it exhausts its retries.
+// It reports whether the item is moved to the dead letters
+func (q *retryQueue) fail(item *retryItem, txs types.Transactions, err error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inFlight, item.key())
+	item.attempts++
+	item.txs = txs
+	item.lastError = err.Error()
+	if item.attempts >= q.maxAttempts {
+		item.failedAt = time.Now()
+		q.deadLetters[item.key()] = item
+		return true
+	}
+
+	item.nextAttempt = time.Now().Add(q.backoff(item.attempts))
+	q.pending[item.key()] = item
+	return false
+}
+
+// done ends the attempt of an item taken by due
+func (q *retryQueue) done(item *retryItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inFlight, item.key())
+}
+
+// retrying reports whether a block number is pending or being attempted. The caller holds the lock
+func (q *retryQueue) retrying(blockNumber uint64) bool {
+	for key := range q.pending {
+		if key.blockNumber == blockNumber {
+			return true
+		}
+	}
+	for key := range q.inFlight {
+		if key.blockNumber == blockNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reprocess moves the dead letters of a block number back to the pending items, with their attempts reset. A block which is
+// being retried already, e.g. by an earlier request, is left as it is, and reported by retrying
+func (q *retryQueue) reprocess(blockNumber uint64) (moved int, retrying bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.retrying(blockNumber) {
+		return 0, true
+	}
+	for key, item := range q.deadLetters {
+		if item.blockNumber != blockNumber {
+			continue
+		}
+
+		item.attempts = 0
+		item.nextAttempt = time.Now()
+		q.pending[key] = item
+		delete(q.deadLetters, key)
+		moved++
+	}
+
+	return moved, false
+}
+
+// backoff doubles the wait per attempt, up to the maximum backoff
+func (q *retryQueue) backoff(attempts int) time.Duration {
+	if attempts > 30 {
+		return maxRetryBackoff
+	}
+
+	return min(q.baseBackoff<<attempts, maxRetryBackoff)
+}
+
+func (item *retryItem) key() retryKey {
+	return retryKey{blockNumber: item.blockNumber, blockHash: item.blockHash}
+}
+
+// ProcessRetryQueue retries the receipts of the failed transactions when their backoff is over
+func (ec *ethClient) ProcessRetryQueue(ctx context.Context) {
+	ctx = withBackfillPriority(ctx)
+	ticker := time.NewTicker(retryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ec.logger.Println("Context cancelled, stopping retry processor")
+			return
+		case <-ticker.C:
+			for _, item := range ec.retries.due() {
+				ec.retry(ctx, item)
+				ec.retries.done(item)
+			}
+		}
+	}
+}
+
+// retry retrieves the receipts of the failed transactions of a block again, and marks the block processed when all are retrieved
+func (ec *ethClient) retry(ctx context.Context, item *retryItem) {
+	if item.blockHash == (common.Hash{}) {
+		ec.retryFetch(ctx, item)
+		return
+	}
+
+	// the block may be evicted from the window or rolled back by a chain reorganization meanwhile
+	stored, err := ec.db.GetBlockByNumber(ctx, item.blockNumber)
+	if err != nil || stored.Hash() != item.blockHash {
+		ec.logger.Printf("block %d (%v) is not stored anymore, retry dropped", item.blockNumber, item.blockHash)
+		return
+	}
+
+	receipts, err := ec.getBatchedReceipts(ctx, item.txs)
+	ec.storeReceiptLogs(ctx, stored, receipts)
+	if err != nil {
+		ec.logger.Printf("retry %d of block %d failed: %v", item.attempts+1, item.blockNumber, err)
+		if ec.retries.fail(item, failedTransactions(item.txs, receipts), err) {
+			ec.storeDeadLetters(ctx)
+		}
+		return
+	}
+
+	ec.logger.Printf("block %d is fully processed after %d retries", item.blockNumber, item.attempts+1)
+	ec.markBlockProcessed(ctx, item.blockNumber)
+}
+
+// retryFetch fetches a block which could not be fetched again, and ingests it
+func (ec *ethClient) retryFetch(ctx context.Context, item *retryItem) {
+	// the block may be stored by the backfill or the reorganization of a later head meanwhile
+	if _, err := ec.db.GetBlockByNumber(ctx, item.blockNumber); err == nil {
+		return
+	}
+
+	block, err := ec.GetBlockByNumber(ctx, new(big.Int).SetUint64(item.blockNumber))
+	if err != nil {
+		ec.logger.Printf("retry %d of fetching block %d failed: %v", item.attempts+1, item.blockNumber, err)
+		if ec.retries.fail(item, nil, err) {
+			ec.storeDeadLetters(ctx)
+		}
+		return
+	}
+
+	ec.logger.Printf("block %d is fetched after %d retries", item.blockNumber, item.attempts+1)
+	ec.ingestBlock(ctx, block)
+}
+
+// enqueueFailedFetch enqueues a block which could not be fetched to be fetched again with backoff
+func (ec *ethClient) enqueueFailedFetch(blockNumber uint64, err error) {
+	ec.logger.Printf("failed to fetch block %d, enqueued to be retried: %v", blockNumber, err)
+	ec.retries.enqueueFetch(blockNumber, err)
+}
+
+// GetDeadLetters gets the blocks whose failed transactions exhausted their retries
+func (ec *ethClient) GetDeadLetters(ctx context.Context) []models.DeadLetter {
+	ec.retries.mu.Lock()
+	defer ec.retries.mu.Unlock()
+
+	deadLetters := make([]models.DeadLetter, 0, len(ec.retries.deadLetters))
+	for _, item := range ec.retries.deadLetters {
+		txHashes := make([]string, len(item.txs))
+		for i, tx := range item.txs {
+			txHashes[i] = tx.Hash().Hex()
+		}
+
+		var blockHash string
+		if item.blockHash != (common.Hash{}) {
+			blockHash = item.blockHash.Hex()
+		}
+		deadLetters = append(deadLetters, models.DeadLetter{
+			BlockNumber: item.blockNumber,
+			BlockHash:   blockHash,
+			TxHashes:    txHashes,
+			Attempts:    item.attempts,
+			LastError:   item.lastError,
+			FailedAt:    item.failedAt,
+		})
+	}
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].BlockNumber < deadLetters[j].BlockNumber
+	})
+
+	return deadLetters
+}
+
+// ReprocessDeadLetters triggers the retries of the dead letters of a block again. A repeated request for a block which is being
+// retried is ignored, so it does not spend the rate budget of the node again
+func (ec *ethClient) ReprocessDeadLetters(ctx context.Context, blockNumber uint64) error {
+	moved, retrying := ec.retries.reprocess(blockNumber)
+	if retrying {
+		ec.logger.Printf("block %d is being retried already, reprocessing ignored", blockNumber)
+		return nil
+	}
+	if moved == 0 {
+		return customerror.NewNotFoundError("dead letter does not exist", fmt.Errorf("no dead letter exists for block %d", blockNumber))
+	}
+
+	ec.logger.Printf("dead letters of block %d are enqueued to be reprocessed", blockNumber)
+	ec.storeDeadLetters(ctx)
+	return nil
+}
+
+// storeDeadLetters stores the dead letters, so they survive a restart
+func (ec *ethClient) storeDeadLetters(ctx context.Context) {
+	if err := ec.db.SetDeadLetters(ctx, ec.GetDeadLetters(ctx)); err != nil {
+		ec.logger.Printf("failed to store the dead letters: %v", err)
+	}
+}
+
+// restoreDeadLetters loads the stored dead letters into the retry queue. The transactions of a dead letter are taken from its stored
+// block, the dead letters of the blocks which are not stored anymore are dropped
+func (ec *ethClient) restoreDeadLetters(ctx context.Context) {
+	deadLetters, err := ec.db.GetDeadLetters(ctx)
+	if err != nil {
+		ec.logger.Printf("failed to load the dead letters: %v", err)
+		return
+	}
+
+	ec.retries.mu.Lock()
+	defer ec.retries.mu.Unlock()
+
+	for _, deadLetter := range deadLetters {
+		item := &retryItem{blockNumber: deadLetter.BlockNumber, attempts: deadLetter.Attempts, lastError: deadLetter.LastError, failedAt: deadLetter.FailedAt}
+		if deadLetter.BlockHash != "" {
+			item.blockHash = common.HexToHash(deadLetter.BlockHash)
+			block, err := ec.db.GetBlockByNumber(ctx, deadLetter.BlockNumber)
+			if err != nil || block.Hash() != item.blockHash {
+				ec.logger.Printf("block %d (%s) of a dead letter is not stored anymore, dead letter dropped", deadLetter.BlockNumber, deadLetter.BlockHash)
+				continue
+			}
+			for _, tx := range block.Transactions() {
+				if slices.Contains(deadLetter.TxHashes, tx.Hash().Hex()) {
+					item.txs = append(item.txs, tx)
+				}
+			}
+		}
+		ec.retries.deadLetters[item.key()] = item
+	}
+}
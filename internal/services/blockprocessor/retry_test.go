@@ -0,0 +1,112 @@
+package blockprocessor
+
+import (
+	"context"
+	"errors"
+	"ethereum-tracker-app/cmd/config"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryQueueDeadLetters(t *testing.T) {
+	maxAttempts := 3
+	queue := newRetryQueue(maxAttempts, 0)
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1})
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)}).WithBody(types.Body{Transactions: types.Transactions{tx}})
+	queue.enqueue(block, block.Transactions(), errors.New("receipt not available"))
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		items := queue.due()
+		assert.Len(t, items, 1, "attempt %d", attempt)
+		assert.Empty(t, queue.due(), "a taken item is not due twice")
+
+		queue.fail(items[0], items[0].txs, errors.New("receipt not available"))
+	}
+
+	assert.Empty(t, queue.pending)
+	assert.Len(t, queue.deadLetters, 1)
+	assert.Equal(t, maxAttempts, queue.deadLetters[retryKey{blockNumber: 7, blockHash: block.Hash()}].attempts)
+
+	moved, retrying := queue.reprocess(8)
+	assert.Equal(t, 0, moved)
+	assert.False(t, retrying)
+	moved, _ = queue.reprocess(7)
+	assert.Equal(t, 1, moved)
+	assert.Empty(t, queue.deadLetters)
+
+	// a repeated request is ignored while the block is pending or being attempted
+	moved, retrying = queue.reprocess(7)
+	assert.Equal(t, 0, moved)
+	assert.True(t, retrying)
+	items := queue.due()
+	assert.Len(t, items, 1)
+	assert.Equal(t, 0, items[0].attempts)
+	_, retrying = queue.reprocess(7)
+	assert.True(t, retrying)
+
+	queue.done(items[0])
+	_, retrying = queue.reprocess(7)
+	assert.False(t, retrying)
+}
+
+func TestRetryBackoffClamped(t *testing.T) {
+	queue := newRetryQueue(100, time.Second)
+
+	assert.Equal(t, 4*time.Second, queue.backoff(2))
+	assert.Equal(t, maxRetryBackoff, queue.backoff(12))
+	assert.Equal(t, maxRetryBackoff, queue.backoff(70), "the shift does not overflow")
+}
+
+func TestFailedFetchRetried(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeNode()
+	chain := newTestChain(nil, 1, 3, "canonical")
+	node.setCanonical(chain...)
+	node.failures[2] = 1
+	node.failures[3] = 100
+	ec, db := newTestEthClient(t, node, config.Config{EthClientConf: config.EthClientConf{RetryMaxAttempts: 2}})
+
+	ec.processNewHead(ctx, 2, 0)
+	ec.processNewHead(ctx, 3, 2)
+	_, err := db.GetBlockByNumber(ctx, 2)
+	require.Error(t, err, "the failed fetch is not ingested yet")
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, item := range ec.retries.due() {
+			ec.retry(ctx, item)
+		}
+	}
+
+	stored, err := db.GetBlockByNumber(ctx, 2)
+	require.NoError(t, err, "the failed fetch is retried")
+	assert.Equal(t, chain[1].Hash(), stored.Hash())
+
+	deadLetters, err := db.GetDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1, "the fetch failing all the retries is a stored dead letter")
+	assert.Equal(t, uint64(3), deadLetters[0].BlockNumber)
+	assert.Empty(t, deadLetters[0].BlockHash)
+
+	// the stored dead letters are restored on restart, and reprocessed by fetching the block again
+	restarted, _ := newTestEthClient(t, node, config.Config{EthClientConf: config.EthClientConf{RetryMaxAttempts: 2}})
+	restarted.db = db
+	restarted.restoreDeadLetters(ctx)
+	assert.Equal(t, deadLetters, restarted.GetDeadLetters(ctx))
+
+	node.failures[3] = 0
+	require.NoError(t, restarted.ReprocessDeadLetters(ctx, 3))
+	for _, item := range restarted.retries.due() {
+		restarted.retry(ctx, item)
+	}
+	_, err = db.GetBlockByNumber(ctx, 3)
+	assert.NoError(t, err)
+	deadLetters, err = db.GetDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
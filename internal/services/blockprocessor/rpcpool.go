@@ -0,0 +1,223 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	healthSmoothingFactor  = 0.2 // weight of the latest call in the moving averages of the error rate and the latency
+	maxConsecutiveFailures = 3
+	endpointCooldown       = 30 * time.Second
+)
+
+var errNoEndpoint = errors.New("no endpoint of the pool can serve the call")
+
+// rpcEndpoint is an http endpoint of an ethereum node with its health statistics
+type rpcEndpoint struct {
+	url    string
+	client *ethclient.Client
+
+	mu                  sync.Mutex
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	errorRate           float64       // exponentially weighted moving average
+	latency             time.Duration // exponentially weighted moving average
+	cooldownUntil       time.Time
+
+	pause                    rateLimitPause
+	blockReceiptsUnsupported atomic.Bool // set when the endpoint does not support eth_getBlockReceipts
+}
+
+// rpcPool routes the calls to the healthiest endpoint and fails over to the next one on errors. Every call is rate limited
+type rpcPool struct {
+	endpoints []*rpcEndpoint
+	limiter   *rateLimiter
+}
+
+func newRPCPool(ctx context.Context, urls []string, limiter *rateLimiter) (*rpcPool, error) {
+	if len(urls) == 0 {
+		return nil, customerror.NewConnectionError("no http url of ethereum node is configured", nil)
+	}
+
+	pool := &rpcPool{endpoints: make([]*rpcEndpoint, 0, len(urls)), limiter: limiter}
+	for _, endpointURL := range urls {
+		client, err := ethclient.DialContext(ctx, endpointURL)
+		if err != nil {
+			return nil, customerror.NewConnectionError("", errors.Wrapf(err, "cannot connet to http url %s of ethereum node", redactURL(endpointURL)))
+		}
+		pool.endpoints = append(pool.endpoints, &rpcEndpoint{url: endpointURL, client: client})
+	}
+
+	return pool, nil
+}
+
+// callPool calls a method on the endpoints of the pool, from the healthiest one, until a call does not fail because of the endpoint
+func callPool[T any](ctx context.Context, p *rpcPool, call func(client *ethclient.Client) (T, error)) (T, error) {
+	return callPoolWithCost(ctx, p, 1, call)
+}
+
+// callPoolWithCost is callPool for a call costing several requests of the rate limit budget, like a batch call
+func callPoolWithCost[T any](ctx context.Context, p *rpcPool, cost int, call func(client *ethclient.Client) (T, error)) (T, error) {
+	return callEndpoints(ctx, p, cost, nil, func(endpoint *rpcEndpoint) (T, error) {
+		return call(endpoint.client)
+	})
+}
+
+// callEndpoints is callPoolWithCost skipping the endpoints which cannot serve the call, if skip is not nil. It fails with
+// errNoEndpoint if every endpoint is skipped
+func callEndpoints[T any](ctx context.Context, p *rpcPool, cost int, skip func(endpoint *rpcEndpoint) bool, call func(endpoint *rpcEndpoint) (T, error)) (T, error) {
+	var result T
+	err := errNoEndpoint
+	for _, endpoint := range p.rankedEndpoints() {
+		if skip != nil && skip(endpoint) {
+			continue
+		}
+		// a paused endpoint is ranked last, so it is waited for only if all the others failed or are paused too
+		if err := endpoint.pause.wait(ctx); err != nil {
+			return result, err
+		}
+		if err := p.limiter.wait(ctx, cost); err != nil {
+			return result, err
+		}
+
+		start := time.Now()
+		result, err = call(endpoint)
+		p.limiter.observe(err)
+		endpoint.pause.observe(err)
+		if !isEndpointFailure(ctx, err) {
+			endpoint.recordSuccess(time.Since(start))
+			return result, err
+		}
+
+		endpoint.recordFailure()
+	}
+
+	return result, err
+}
+
+// rankedEndpoints sorts the endpoints by their health score. The endpoints in cooldown or paused by a rate limit reply are kept as
+// the last resort
+func (p *rpcPool) rankedEndpoints() []*rpcEndpoint {
+	type rankedEndpoint struct {
+		endpoint *rpcEndpoint
+		healthy  bool
+		score    float64
+	}
+
+	now := time.Now()
+	ranked := make([]rankedEndpoint, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		_, paused := endpoint.pause.until(now)
+		endpoint.mu.Lock()
+		ranked[i] = rankedEndpoint{
+			endpoint: endpoint,
+			healthy:  !now.Before(endpoint.cooldownUntil) && !paused,
+			// lower is better: the latency, penalized by the error rate. The baseline keeps the penalty for the endpoints without latency samples
+			score: float64(endpoint.latency+time.Millisecond) * (1 + 10*endpoint.errorRate),
+		}
+		endpoint.mu.Unlock()
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].healthy != ranked[j].healthy {
+			return ranked[i].healthy
+		}
+		return ranked[i].score < ranked[j].score
+	})
+
+	endpoints := make([]*rpcEndpoint, len(ranked))
+	for i := range ranked {
+		endpoints[i] = ranked[i].endpoint
+	}
+
+	return endpoints
+}
+
+// health gets the health statistics of the endpoints, without exposing the secrets of their urls
+func (p *rpcPool) health() []models.EndpointHealth {
+	now := time.Now()
+	health := make([]models.EndpointHealth, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		pausedUntil, paused := endpoint.pause.until(now)
+		endpoint.mu.Lock()
+		health[i] = models.EndpointHealth{
+			URL:       redactURL(endpoint.url),
+			Healthy:   !now.Before(endpoint.cooldownUntil),
+			Requests:  endpoint.requests,
+			Failures:  endpoint.failures,
+			ErrorRate: endpoint.errorRate,
+			LatencyMs: endpoint.latency.Milliseconds(),
+		}
+		if paused {
+			health[i].PausedUntil = &pausedUntil
+		}
+		endpoint.mu.Unlock()
+	}
+
+	return health
+}
+
+func (e *rpcEndpoint) recordSuccess(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	e.consecutiveFailures = 0
+	e.errorRate = (1 - healthSmoothingFactor) * e.errorRate
+	if e.latency == 0 {
+		e.latency = latency
+		return
+	}
+	e.latency = time.Duration((1-healthSmoothingFactor)*float64(e.latency) + healthSmoothingFactor*float64(latency))
+}
+
+func (e *rpcEndpoint) recordFailure() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	e.failures++
+	e.consecutiveFailures++
+	e.errorRate = (1-healthSmoothingFactor)*e.errorRate + healthSmoothingFactor
+	if e.consecutiveFailures >= maxConsecutiveFailures {
+		e.cooldownUntil = time.Now().Add(endpointCooldown)
+	}
+}
+
+// isEndpointFailure reports whether an error is caused by the endpoint, so the call should fail over to another endpoint.
+// Missing data, unsupported methods and the cancellation of the caller are not failures of the endpoint
+func isEndpointFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+		return false
+	}
+
+	return !errors.Is(err, ethereum.NotFound)
+}
+
+// redactURL keeps only the scheme and the host of a url, as the path or the query usually carries the api key
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "<invalid url>"
+	}
+
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,90 @@
+package blockprocessor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCPoolFailover(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer healthy.Close()
+
+	ctx := context.Background()
+	pool, err := newRPCPool(ctx, []string{failing.URL, healthy.URL}, newRateLimiter(0, 0))
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		blockNumber, err := callPool(ctx, pool, func(client *ethclient.Client) (uint64, error) {
+			return client.BlockNumber(ctx)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(16), blockNumber)
+	}
+
+	// after the first failover, the healthy endpoint is ranked first
+	health := pool.health()
+	assert.Equal(t, uint64(1), health[0].Failures)
+	assert.Equal(t, uint64(3), health[1].Requests)
+	assert.Equal(t, uint64(0), health[1].Failures)
+	assert.Equal(t, healthy.URL, pool.rankedEndpoints()[0].url)
+
+	for i := 1; i < maxConsecutiveFailures; i++ {
+		pool.endpoints[0].recordFailure()
+	}
+	assert.False(t, pool.health()[0].Healthy, "the failing endpoint must be in cooldown")
+}
+
+func TestRateLimitPausesOnlyTheEndpoint(t *testing.T) {
+	var limitedCalls atomic.Int32
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limitedCalls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer healthy.Close()
+
+	ctx := context.Background()
+	pool, err := newRPCPool(ctx, []string{limited.URL, healthy.URL}, newRateLimiter(0, 0))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		blockNumber, err := callPool(ctx, pool, func(client *ethclient.Client) (uint64, error) {
+			return client.BlockNumber(ctx)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(16), blockNumber)
+	}
+
+	assert.Less(t, time.Since(start), minRateLimitBackoff, "the other endpoint is not paused")
+	assert.Equal(t, int32(1), limitedCalls.Load(), "the paused endpoint is not called")
+	health := pool.health()
+	assert.NotNil(t, health[0].PausedUntil)
+	assert.Nil(t, health[1].PausedUntil)
+	assert.Equal(t, uint64(1), pool.limiter.budget().RateLimitedReplies)
+}
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "https://mainnet.infura.io", redactURL("https://mainnet.infura.io/v3/secret-key"))
+	assert.Equal(t, "<invalid url>", redactURL("not a url"))
+}
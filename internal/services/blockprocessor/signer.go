@@ -0,0 +1,85 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+)
+
+// knownChainConfigs are the chains whose fork schedules are known, by their chain id
+var knownChainConfigs = map[uint64]*params.ChainConfig{
+	params.MainnetChainConfig.ChainID.Uint64(): params.MainnetChainConfig,
+	params.SepoliaChainConfig.ChainID.Uint64(): params.SepoliaChainConfig,
+	params.HoleskyChainConfig.ChainID.Uint64(): params.HoleskyChainConfig,
+}
+
+// GetChainID gets the chain id of the node. It is retrieved from the node once
+func (ec *ethClient) GetChainID(ctx context.Context) (*big.Int, error) {
+	if chainID := ec.chainID.Load(); chainID != nil {
+		return new(big.Int).Set(chainID), nil
+	}
+
+	chainID, err := callPool(ctx, ec.pool, func(client *ethclient.Client) (*big.Int, error) {
+		return client.ChainID(ctx)
+	})
+	if err != nil {
+		return nil, customerror.NewOnChainDataRetrievalError("", errors.Wrap(err, "cannot fetch the chain id"))
+	}
+	ec.chainID.Store(chainID)
+
+	return new(big.Int).Set(chainID), nil
+}
+
+// blockSigner gets the signer of the transactions of a block, by the fork rules of the chain at the block
+func (ec *ethClient) blockSigner(ctx context.Context, block *types.Block) (types.Signer, error) {
+	chainID, err := ec.GetChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chainConfig, ok := knownChainConfigs[chainID.Uint64()]
+	if !ok {
+		// the fork schedule of the chain is unknown, the latest signer accepts every transaction type
+		return types.LatestSignerForChainID(chainID), nil
+	}
+
+	return types.MakeSigner(chainConfig, block.Number(), block.Time()), nil
+}
+
+// addressTransaction summarizes a transaction of a block for its sender and recipient, from its receipt
+func addressTransaction(signer types.Signer, block *types.Block, receipt *types.Receipt) (*models.AddressTransaction, error) {
+	index := int(receipt.TransactionIndex)
+	if index >= len(block.Transactions()) || block.Transactions()[index].Hash() != receipt.TxHash {
+		return nil, errors.Errorf("transaction %v is not at index %d of block %d", receipt.TxHash, index, block.NumberU64())
+	}
+	tx := block.Transactions()[index]
+
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot recover the sender of transaction %v", receipt.TxHash)
+	}
+
+	transaction := &models.AddressTransaction{
+		Hash:             tx.Hash().Hex(),
+		BlockNumber:      block.NumberU64(),
+		BlockHash:        block.Hash().Hex(),
+		TransactionIndex: index,
+		From:             from.Hex(),
+		Value:            tx.Value().String(),
+		Status:           receipt.Status,
+	}
+	if to := tx.To(); to != nil {
+		transaction.To = to.Hex()
+	} else if receipt.ContractAddress != (common.Address{}) {
+		transaction.ContractAddress = receipt.ContractAddress.Hex()
+	}
+
+	return transaction, nil
+}
@@ -0,0 +1,45 @@
+package blockprocessor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	recipient := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5")
+	contract := crypto.CreateAddress(sender, 1)
+
+	header := &types.Header{Number: big.NewInt(20_000_000), Time: 1_717_000_000, BaseFee: big.NewInt(1)}
+	signer := types.MakeSigner(params.MainnetChainConfig, header.Number, header.Time)
+	transfer := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+		ChainID: params.MainnetChainConfig.ChainID, To: &recipient, Value: big.NewInt(1000), Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2),
+	})
+	creation := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, Gas: 100000, GasPrice: big.NewInt(2)})
+	block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: types.Transactions{transfer, creation}})
+
+	transaction, err := addressTransaction(signer, block, &types.Receipt{TxHash: transfer.Hash(), Status: types.ReceiptStatusSuccessful})
+	assert.NoError(t, err)
+	assert.Equal(t, sender.Hex(), transaction.From)
+	assert.Equal(t, recipient.Hex(), transaction.To)
+	assert.Equal(t, "1000", transaction.Value)
+	assert.Empty(t, transaction.ContractAddress)
+
+	transaction, err = addressTransaction(signer, block, &types.Receipt{TxHash: creation.Hash(), TransactionIndex: 1, ContractAddress: contract})
+	assert.NoError(t, err)
+	assert.Equal(t, sender.Hex(), transaction.From)
+	assert.Empty(t, transaction.To)
+	assert.Equal(t, contract.Hex(), transaction.ContractAddress)
+	assert.Equal(t, 1, transaction.TransactionIndex)
+
+	_, err = addressTransaction(signer, block, &types.Receipt{TxHash: creation.Hash()})
+	assert.Error(t, err, "the receipt does not match the transaction at its index")
+}
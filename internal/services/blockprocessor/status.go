@@ -0,0 +1,70 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+	"sync"
+)
+
+// syncStatus keeps the state of the ingestion, so it is visible to the operators
+type syncStatus struct {
+	mu            sync.RWMutex
+	connection    models.ConnectionState
+	lastError     string
+	reconnects    uint64
+	lastSeenBlock uint64
+}
+
+func newSyncStatus() *syncStatus {
+	return &syncStatus{
+		connection: models.ConnectionStateConnecting,
+	}
+}
+
+func (s *syncStatus) setConnection(state models.ConnectionState, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connection = state
+	if err != nil {
+		s.lastError = err.Error()
+	}
+}
+
+func (s *syncStatus) incrementReconnects() {
+	s.mu.Lock()
+	s.reconnects++
+	s.mu.Unlock()
+}
+
+func (s *syncStatus) setLastSeenBlock(number uint64) {
+	s.mu.Lock()
+	s.lastSeenBlock = number
+	s.mu.Unlock()
+}
+
+// GetSyncStatus gets the state of the connection to the node and the progress of the ingestion
+func (ec *ethClient) GetSyncStatus(ctx context.Context) models.SyncStatus {
+	ec.status.mu.RLock()
+	status := models.SyncStatus{
+		Mode:          ec.syncMode,
+		Connection:    ec.status.connection,
+		LastError:     ec.status.lastError,
+		Reconnects:    ec.status.reconnects,
+		LastSeenBlock: ec.status.lastSeenBlock,
+		Endpoints:     ec.pool.health(),
+		RateLimit:     ec.pool.limiter.budget(),
+	}
+	ec.status.mu.RUnlock()
+
+	ec.retries.mu.Lock()
+	status.PendingRetries = len(ec.retries.pending)
+	status.DeadLetters = len(ec.retries.deadLetters)
+	ec.retries.mu.Unlock()
+
+	if checkpoint, err := ec.db.GetCheckpoint(ctx); err == nil {
+		status.Checkpoint = &checkpoint
+	}
+
+	return status
+}
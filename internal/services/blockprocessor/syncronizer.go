@@ -2,42 +2,296 @@ package blockprocessor
 
 import (
 	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
 	"ethereum-tracker-app/pkg/customerror"
+	"math/big"
+	"math/rand/v2"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
 )
 
-// SubscribeToNewGeneratedBlocks retrieves and stores new generated blocks
+const (
+	minReconnectBackoff  = 100 * time.Millisecond // floor of the reconnect backoff, so a zero setting does not redial in a tight loop
+	minBackfillQueueSize = 64                     // ranges of missing blocks, so a small or unbounded window still buffers the gaps
+)
+
+// blockRange is a gap of missing blocks, from and to included
+type blockRange struct {
+	from, to uint64
+}
+
+// SyncNewGeneratedBlocks retrieves and stores new generated blocks, by the subscription or polling whichever is selected
+func (ec *ethClient) SyncNewGeneratedBlocks(ctx context.Context) error {
+	if ec.syncMode == config.SyncModePolling {
+		return ec.PollNewGeneratedBlocks(ctx)
+	}
+
+	return ec.SubscribeToNewGeneratedBlocks(ctx)
+}
+
+// SubscribeToNewGeneratedBlocks retrieves and stores new generated blocks. The subscription is renewed whenever it fails
 func (ec *ethClient) SubscribeToNewGeneratedBlocks(ctx context.Context) error {
 	headers := make(chan *types.Header)
 	sub, err := ec.SubscribeNewHeadersViaWss(ctx, headers)
 	if err != nil {
 		return customerror.NewOnChainDataRetrievalError("", errors.Wrapf(err, "failed to subscribe to new headers of the blockchain"))
 	}
+	ec.status.setConnection(models.ConnectionStateConnected, nil)
+
+	go ec.processBackfillQueue(ctx)
 
+	var lastSeen uint64
 	for {
 		select {
 		case <-ctx.Done():
+			sub.Unsubscribe()
 			ec.logger.Println("Context cancelled, stopping block subscription")
 			return nil
 		case err := <-sub.Err():
 			ec.logger.Printf(customerror.NewOnChainDataRetrievalError("error in header subscription", err).Error())
+			ec.status.setConnection(models.ConnectionStateReconnecting, err)
+
+			if sub = ec.resubscribe(ctx, headers); sub == nil {
+				ec.logger.Println("Context cancelled, stopping block subscription")
+				return nil
+			}
+
+			// backfill the blocks produced while disconnected
+			if latest, err := ec.GetBlockNumber(ctx); err == nil && lastSeen != 0 && latest > lastSeen {
+				ec.enqueueBackfill(ctx, lastSeen+1, latest)
+				lastSeen = latest
+			}
 		case header := <-headers:
 			ec.logger.Printf("New block received: %v \n", header.Number.String())
 
-			block, err := ec.GetBlockByNumber(ctx, header.Number)
+			lastSeen = ec.processNewHead(ctx, header.Number.Uint64(), lastSeen)
+		}
+	}
+}
+
+// PollNewGeneratedBlocks retrieves and stores new generated blocks by polling the latest block number, for the nodes without a wss url
+func (ec *ethClient) PollNewGeneratedBlocks(ctx context.Context) error {
+	ec.status.setConnection(models.ConnectionStatePolling, nil)
+
+	go ec.processBackfillQueue(ctx)
+
+	ticker := time.NewTicker(ec.config.EthClientConf.PollingInterval)
+	defer ticker.Stop()
+
+	var lastSeen uint64
+	for {
+		select {
+		case <-ctx.Done():
+			ec.logger.Println("Context cancelled, stopping block polling")
+			return nil
+		case <-ticker.C:
+			latest, err := ec.GetBlockNumber(ctx)
 			if err != nil {
-				ec.logger.Printf("failed to fetch block details of block %d: %v", header.Number, err)
+				ec.logger.Printf("failed to poll the latest block number: %v", err)
+				ec.status.setConnection(models.ConnectionStatePolling, err)
+				continue
+			}
+			if latest <= lastSeen {
+				// a lagging endpoint of the pool can serve an older head, a reorg to a shorter chain is resolved by the next new head
 				continue
 			}
 
-			if setErr := ec.db.SetBlock(context.Background(), block); setErr != nil {
-				ec.logger.Printf("block %d has not been stored in the datastore", block.NumberU64())
-				// todo having exra mechanism to handle this occasion to store blocks in case of error
+			ec.logger.Printf("New block polled: %d \n", latest)
+			lastSeen = ec.processNewHead(ctx, latest, lastSeen)
+		}
+	}
+}
+
+// processNewHead ingests the block of a new head, and enqueues the blocks skipped since the last seen head for backfill. It returns
+// the last seen head, which is kept if the new head is an older block of the stored chain
+func (ec *ethClient) processNewHead(ctx context.Context, number, lastSeen uint64) uint64 {
+	// the subscription feed can jump several numbers, e.g. after a provider hiccup, and polling usually does
+	if lastSeen != 0 && number > lastSeen+1 {
+		ec.enqueueBackfill(ctx, lastSeen+1, number-1)
+	}
+
+	block, err := ec.GetBlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		ec.enqueueFailedFetch(number, err)
+		return max(number, lastSeen)
+	}
+
+	// only a head replacing the stored chain orphans the stored blocks above it, an older head of the stored chain, e.g. of a
+	// lagging endpoint, is ignored
+	reorganized := ec.isReorganizedHead(ctx, block)
+	if number <= lastSeen && !reorganized {
+		ec.logger.Printf("ignoring block %d behind the last seen block %d, as it is part of the stored chain", number, lastSeen)
+		return lastSeen
+	}
+	if reorganized {
+		ec.rollbackBlocksAbove(ctx, number)
+	}
+	ec.status.setLastSeenBlock(number)
+
+	ec.ingestBlock(ctx, block)
+	if time.Since(ec.tagsRefreshedAt) >= ec.config.EthClientConf.BlockTagsRefreshInterval {
+		ec.refreshBlockTags(ctx)
+	}
+
+	return number
+}
+
+// refreshBlockTags stores the numbers of the safe and the finalized blocks of the node, so the queries can refer to them.
+// The nodes without the finality, e.g. of the chains before the merge, do not have them. They advance once per epoch, so they are
+// refreshed every BLOCK_TAGS_REFRESH_INTERVAL rather than on every new head
+func (ec *ethClient) refreshBlockTags(ctx context.Context) {
+	ec.tagsRefreshedAt = time.Now()
+	tags := map[models.BlockTag]rpc.BlockNumber{
+		models.BlockTagSafe:      rpc.SafeBlockNumber,
+		models.BlockTagFinalized: rpc.FinalizedBlockNumber,
+	}
+
+	for tag, blockNumber := range tags {
+		header, err := callPool(ctx, ec.pool, func(client *ethclient.Client) (*types.Header, error) {
+			return client.HeaderByNumber(ctx, big.NewInt(blockNumber.Int64()))
+		})
+		if err != nil {
+			ec.logger.Printf("failed to get the %s block: %v", tag, err)
+			continue
+		}
+
+		if err := ec.db.SetBlockTag(ctx, tag, header.Number.Uint64()); err != nil {
+			ec.logger.Printf("failed to store the %s block %d: %v", tag, header.Number.Uint64(), err)
+		}
+	}
+}
+
+// resubscribe redials the wss url of the node and subscribes to the new headers again, with exponential backoff and jitter.
+// It returns nil only if the context is cancelled
+func (ec *ethClient) resubscribe(ctx context.Context, headers chan<- *types.Header) ethereum.Subscription {
+	backoff := max(ec.config.EthClientConf.ReconnectMinBackoff, minReconnectBackoff)
+	maxBackoff := max(ec.config.EthClientConf.ReconnectMaxBackoff, backoff)
+	for attempt := 1; ; attempt++ {
+		// full jitter on the upper half, so the reconnecting instances do not hit the node all at once
+		wait := backoff/2 + rand.N(backoff/2+1)
+		ec.logger.Printf("resubscribing to new headers in %v (attempt %d)", wait, attempt)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		sub, err := ec.redialAndSubscribe(ctx, headers)
+		if err == nil {
+			ec.logger.Printf("resubscribed to new headers after %d attempts", attempt)
+			ec.status.setConnection(models.ConnectionStateConnected, nil)
+			ec.status.incrementReconnects()
+			return sub
+		}
+
+		ec.logger.Printf("failed to resubscribe to new headers: %v", err)
+		ec.status.setConnection(models.ConnectionStateReconnecting, err)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// redialAndSubscribe replaces the wss client of the node with a new connection and subscribes to the new headers.
+// The wss urls are dialed in turn, starting from the one after the failed url
+func (ec *ethClient) redialAndSubscribe(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error) {
+	ec.wsMu.RLock()
+	nextIndex := ec.wsURLIndex + 1
+	ec.wsMu.RUnlock()
+
+	rpcClient, index, err := dialNextWss(ctx, ec.config.EthClientConf.EthereumWSSURLs, nextIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	ec.wsMu.Lock()
+	ec.wsClient.Close()
+	ec.wsClient = rpcClient
+	ec.wsURLIndex = index
+	ec.wsMu.Unlock()
+
+	return ec.SubscribeNewHeadersViaWss(ctx, headers)
+}
+
+// ingestBlock stores a block, together with the canonical blocks replacing the orphaned ones in case of a chain reorganization,
+// and extracts their events
+func (ec *ethClient) ingestBlock(ctx context.Context, block *types.Block) {
+	canonicalBlocks, err := ec.resolveCanonicalChain(ctx, block)
+	if err != nil {
+		ec.logger.Printf("failed to resolve the canonical chain of block %d: %v", block.NumberU64(), err)
+		return
+	}
+
+	for _, canonicalBlock := range canonicalBlocks {
+		if setErr := ec.storeBlock(context.Background(), canonicalBlock); setErr != nil {
+			ec.logger.Printf("block %d has not been stored in the datastore: %v", canonicalBlock.NumberU64(), setErr)
+			// todo having exra mechanism to handle this occasion to store blocks in case of error
+		} else if !isBackfill(ctx) {
+			// the backfilled blocks are behind the head
+			ec.feed.PublishHead(canonicalBlock.Header())
+		}
+
+		if err := ec.ExtractEvents(ctx, canonicalBlock); err != nil {
+			ec.logger.Printf("block %d is not fully processed: %v", canonicalBlock.NumberU64(), err)
+			continue
+		}
+		ec.markBlockProcessed(ctx, canonicalBlock.NumberU64())
+	}
+}
+
+// enqueueBackfill enqueues a range of missing block numbers to be fetched, capped at the window of the recent blocks. The range is
+// a single item whatever its length, and it is handed over by a goroutine when the queue is full, so the head loop never blocks
+func (ec *ethClient) enqueueBackfill(ctx context.Context, from, to uint64) {
+	if windowSize := uint64(ec.config.EthClientConf.NumberOfRecentBlocks); windowSize != 0 && to >= windowSize && from < to-windowSize+1 {
+		from = to - windowSize + 1
+	}
+	ec.logger.Printf("blocks %d to %d are missing, enqueued for backfill", from, to)
+
+	gap := blockRange{from: from, to: to}
+	select {
+	case ec.backfillQueue <- gap:
+	default:
+		go func() {
+			select {
+			case <-ctx.Done():
+			case ec.backfillQueue <- gap:
 			}
+		}()
+	}
+}
 
-			ec.ExtractEvents(ctx, block.Transactions())
+// processBackfillQueue fetches and ingests the enqueued missing blocks which are not stored yet
+func (ec *ethClient) processBackfillQueue(ctx context.Context) {
+	ctx = withBackfillPriority(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			ec.logger.Println("Context cancelled, stopping backfill processor")
+			return
+		case gap := <-ec.backfillQueue:
+			for number := gap.from; number <= gap.to && ctx.Err() == nil; number++ {
+				ec.backfillBlock(ctx, number)
+			}
 		}
 	}
 }
+
+// backfillBlock fetches and ingests a missing block, unless it is stored meanwhile
+func (ec *ethClient) backfillBlock(ctx context.Context, number uint64) {
+	if _, err := ec.db.GetBlockByNumber(ctx, number); err == nil {
+		return
+	}
+
+	block, err := ec.GetBlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		ec.enqueueFailedFetch(number, err)
+		return
+	}
+
+	ec.ingestBlock(ctx, block)
+}
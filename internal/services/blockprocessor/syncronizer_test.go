@@ -0,0 +1,69 @@
+package blockprocessor
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueBackfill(t *testing.T) {
+	type testCase struct {
+		name       string
+		windowSize int
+		from, to   uint64
+		want       blockRange
+	}
+
+	testcases := []testCase{
+		{name: "gap in the window", windowSize: 50, from: 10, to: 20, want: blockRange{from: 10, to: 20}},
+		{name: "gap capped at the window", windowSize: 50, from: 10, to: 1000, want: blockRange{from: 951, to: 1000}},
+		{name: "large gap without a window", from: 1, to: 20_000_000, want: blockRange{from: 1, to: 20_000_000}},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := &ethClient{
+				config:        config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: tt.windowSize}},
+				logger:        log.New(os.Stdout, "app", log.LstdFlags),
+				backfillQueue: make(chan blockRange, 1),
+			}
+
+			ec.enqueueBackfill(context.Background(), tt.from, tt.to)
+			assert.Equal(t, tt.want, <-ec.backfillQueue)
+		})
+	}
+}
+
+func TestEnqueueBackfillDoesNotBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ec := &ethClient{
+		config:        config.Config{},
+		logger:        log.New(os.Stdout, "app", log.LstdFlags),
+		backfillQueue: make(chan blockRange, 1),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		// the second gap finds the queue full
+		ec.enqueueBackfill(ctx, 1, 10)
+		ec.enqueueBackfill(ctx, 20, 30)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "the enqueueing of a gap blocked on the full queue")
+	}
+
+	assert.Equal(t, blockRange{from: 1, to: 10}, <-ec.backfillQueue)
+	assert.Equal(t, blockRange{from: 20, to: 30}, <-ec.backfillQueue)
+}
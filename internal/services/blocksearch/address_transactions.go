@@ -0,0 +1,23 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/pkg/customerror"
+
+	"github.com/pkg/errors"
+)
+
+// GetTransactionsByAddress gets a page of the transactions sent or received by an address, which match the filter
+func (b *blockprocess) GetTransactionsByAddress(ctx context.Context, address string, filter TransactionFilter, page Page) (AddressTransactionsPage, error) {
+	transactions, err := b.db.GetTransactionsByAddress(ctx, address)
+	if err != nil {
+		return AddressTransactionsPage{}, customerror.NewStorageError("", errors.Wrapf(err, "failed to get transactions of address %s", address))
+	}
+
+	pageTransactions, next, err := paginate(filter.apply(address, transactions), addressTxCursor, page)
+	if err != nil {
+		return AddressTransactionsPage{}, err
+	}
+
+	return AddressTransactionsPage{Transactions: pageTransactions, Next: next}, nil
+}
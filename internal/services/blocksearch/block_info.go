@@ -3,23 +3,46 @@ package blocksearch
 import (
 	"context"
 	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
 	"ethereum-tracker-app/pkg/customerror"
+	"fmt"
 	"log"
+	"math"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/pkg/errors"
 )
 
 type Service interface {
-	GetEventsByAddress(ctx context.Context, address string) ([]types.Log, error)
+	GetEventsByAddress(ctx context.Context, address string, filter EventFilter, page Page) (EventsPage, error)
+	GetTransfersByAddress(ctx context.Context, address string, filter TransferFilter, page Page) (TransfersPage, error)
+	GetTransfersByToken(ctx context.Context, token string, page Page) (TransfersPage, error)
+	GetBlock(ctx context.Context, ref BlockRef) (models.Block, error)
+	GetBlockByHash(ctx context.Context, hash common.Hash) (models.Block, error)
+	GetBlockTransactions(ctx context.Context, ref BlockRef, page Page) (TransactionsPage, error)
+	GetTransaction(ctx context.Context, hash common.Hash) (models.Transaction, error)
+	GetTransactionsByAddress(ctx context.Context, address string, filter TransactionFilter, page Page) (AddressTransactionsPage, error)
 }
 
 type storageService interface {
 	SetBlock(ctx context.Context, block *types.Block) error
-	SetTransactionHash(ctx context.Context, blockNumber uint64, txHashHex string) error
+	SetTransactionHashes(ctx context.Context, blockNumber uint64, txHashesHex []string) error
 	SetLogsByTx(ctx context.Context, txHashHex string, logs []*types.Log) error
 	SetLogByAddress(ctx context.Context, addressHex string, log *types.Log) error
 	GetLogsByAddress(ctx context.Context, addressHex string) ([]types.Log, error)
+	GetLogsByAddressInRange(ctx context.Context, addressHex string, role models.AddressRole, fromBlock, toBlock uint64) ([]types.Log, error)
+	GetTransfersByAddress(ctx context.Context, addressHex string) ([]models.Transfer, error)
+	GetTransfersByToken(ctx context.Context, tokenHex string) ([]models.Transfer, error)
+	GetTransactionsByAddress(ctx context.Context, addressHex string) ([]models.AddressTransaction, error)
+	GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	GetBlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	GetLogsByBlock(ctx context.Context, number uint64) ([]types.Log, error)
+	GetTransactionHashes(ctx context.Context, blockNumber uint64) ([]string, error)
+	GetLogsByTx(ctx context.Context, txHashHex string) ([]types.Log, error)
+	GetReceipt(ctx context.Context, txHashHex string) (*types.Receipt, error)
+	GetBlockByTxHash(ctx context.Context, txHashHex string) (*types.Block, int, error)
+	GetBlockTag(ctx context.Context, tag models.BlockTag) (uint64, error)
 }
 
 type blockprocess struct {
@@ -36,12 +59,69 @@ func NewServie(config config.Config, logger *log.Logger, db storageService) Serv
 	}
 }
 
-// GetEventsByAddress gets events of a specific address
-func (b *blockprocess) GetEventsByAddress(ctx context.Context, address string) ([]types.Log, error) {
-	logs, err := b.db.GetLogsByAddress(ctx, address)
+// GetEventsByAddress gets a page of the events of a specific address, which match the filter
+func (b *blockprocess) GetEventsByAddress(ctx context.Context, address string, filter EventFilter, page Page) (EventsPage, error) {
+	fromBlock, toBlock, err := b.resolveBlockRange(ctx, filter)
 	if err != nil {
-		return nil, customerror.NewLogRetrievalError("", errors.Wrapf(err, "failed to get logs of address %s", address))
+		return EventsPage{}, err
 	}
 
-	return logs, nil
+	role := filter.Role
+	if role == "" {
+		role = models.AddressRoleEmitter
+	}
+
+	logs, err := b.db.GetLogsByAddressInRange(ctx, address, role, fromBlock, toBlock)
+	if err != nil {
+		return EventsPage{}, customerror.NewLogRetrievalError("", errors.Wrapf(err, "failed to get logs of address %s", address))
+	}
+
+	events, next, err := paginate(filter.apply(logs), logCursor, page)
+	if err != nil {
+		return EventsPage{}, err
+	}
+
+	return EventsPage{Events: events, Next: next}, nil
+}
+
+// resolveBlockRange gets the inclusive range of block numbers selected by the filter, either by the block hash or by the bounds
+func (b *blockprocess) resolveBlockRange(ctx context.Context, filter EventFilter) (uint64, uint64, error) {
+	if filter.BlockHash != nil {
+		block, err := b.db.GetBlockByHash(ctx, *filter.BlockHash)
+		if err != nil {
+			return 0, 0, err
+		}
+		return block.NumberU64(), block.NumberU64(), nil
+	}
+
+	fromBlock, toBlock := uint64(0), uint64(math.MaxUint64)
+	if filter.FromBlock != nil {
+		number, err := b.resolveBlockRef(ctx, *filter.FromBlock)
+		if err != nil {
+			return 0, 0, err
+		}
+		fromBlock = number
+	}
+	if filter.ToBlock != nil {
+		number, err := b.resolveBlockRef(ctx, *filter.ToBlock)
+		if err != nil {
+			return 0, 0, err
+		}
+		toBlock = number
+	}
+
+	if fromBlock > toBlock {
+		return 0, 0, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: fromBlock %d is after toBlock %d", fromBlock, toBlock), nil)
+	}
+
+	return fromBlock, toBlock, nil
+}
+
+// resolveBlockRef gets the block number of a block reference, looking up the tags in the storage
+func (b *blockprocess) resolveBlockRef(ctx context.Context, ref BlockRef) (uint64, error) {
+	if ref.Tag == "" {
+		return ref.Number, nil
+	}
+
+	return b.db.GetBlockTag(ctx, ref.Tag)
 }
@@ -0,0 +1,71 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEventsByAddressInBlockRange(t *testing.T) {
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297").Hex()
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewServie(conf, logger, db)
+	ctx := context.Background()
+
+	var blocks []*types.Block
+	for number := uint64(1); number <= 5; number++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)})
+		blocks = append(blocks, block)
+		require.NoError(t, db.SetBlock(ctx, block))
+		require.NoError(t, db.SetLogByAddress(ctx, address, &types.Log{BlockNumber: number, BlockHash: block.Hash()}))
+	}
+	require.NoError(t, db.SetBlockTag(ctx, models.BlockTagFinalized, 2))
+
+	type testCase struct {
+		name    string
+		filter  EventFilter
+		numbers []uint64
+		failed  bool
+	}
+
+	hash := blocks[2].Hash()
+	unknownHash := common.HexToHash("0x01")
+	testcases := []testCase{
+		{name: "open range", filter: EventFilter{}, numbers: []uint64{1, 2, 3, 4, 5}},
+		{name: "numbers", filter: EventFilter{FromBlock: &BlockRef{Number: 2}, ToBlock: &BlockRef{Number: 3}}, numbers: []uint64{2, 3}},
+		{name: "tags", filter: EventFilter{FromBlock: &BlockRef{Tag: models.BlockTagFinalized}, ToBlock: &BlockRef{Tag: models.BlockTagLatest}}, numbers: []uint64{2, 3, 4, 5}},
+		{name: "block hash", filter: EventFilter{BlockHash: &hash}, numbers: []uint64{3}},
+		{name: "unknown block hash", filter: EventFilter{BlockHash: &unknownHash}, failed: true},
+		{name: "unknown tag", filter: EventFilter{ToBlock: &BlockRef{Tag: models.BlockTagSafe}}, failed: true},
+		{name: "reversed range", filter: EventFilter{FromBlock: &BlockRef{Number: 4}, ToBlock: &BlockRef{Number: 3}}, failed: true},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := srv.GetEventsByAddress(ctx, address, tt.filter, Page{})
+			if tt.failed {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			numbers := make([]uint64, len(page.Events))
+			for i, event := range page.Events {
+				numbers[i] = event.BlockNumber
+			}
+			assert.Equal(t, tt.numbers, numbers)
+		})
+	}
+}
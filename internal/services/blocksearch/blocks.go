@@ -0,0 +1,92 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// GetBlock gets the summary of an indexed block by its number or tag
+func (b *blockprocess) GetBlock(ctx context.Context, ref BlockRef) (models.Block, error) {
+	number, err := b.resolveBlockRef(ctx, ref)
+	if err != nil {
+		return models.Block{}, err
+	}
+
+	block, err := b.db.GetBlockByNumber(ctx, number)
+	if err != nil {
+		return models.Block{}, err
+	}
+
+	return b.summarizeBlock(ctx, block)
+}
+
+// GetBlockByHash gets the summary of an indexed block by its hash
+func (b *blockprocess) GetBlockByHash(ctx context.Context, hash common.Hash) (models.Block, error) {
+	block, err := b.db.GetBlockByHash(ctx, hash)
+	if err != nil {
+		return models.Block{}, err
+	}
+
+	return b.summarizeBlock(ctx, block)
+}
+
+// GetBlockTransactions gets a page of the hashes of the transactions of an indexed block, in their order in the block
+func (b *blockprocess) GetBlockTransactions(ctx context.Context, ref BlockRef, page Page) (TransactionsPage, error) {
+	number, err := b.resolveBlockRef(ctx, ref)
+	if err != nil {
+		return TransactionsPage{}, err
+	}
+
+	block, err := b.db.GetBlockByNumber(ctx, number)
+	if err != nil {
+		return TransactionsPage{}, err
+	}
+	txHashes, err := b.db.GetTransactionHashes(ctx, number)
+	if err != nil {
+		return TransactionsPage{}, err
+	}
+
+	pageHashes, next, err := paginateTransactions(block.Hash(), txHashes, page)
+	if err != nil {
+		return TransactionsPage{}, err
+	}
+
+	return TransactionsPage{
+		BlockNumber: number,
+		BlockHash:   block.Hash(),
+		TxHashes:    append(make([]string, 0, len(pageHashes)), pageHashes...),
+		Next:        next,
+	}, nil
+}
+
+func (b *blockprocess) summarizeBlock(ctx context.Context, block *types.Block) (models.Block, error) {
+	logs, err := b.db.GetLogsByBlock(ctx, block.NumberU64())
+	if err != nil {
+		return models.Block{}, err
+	}
+
+	txHashes := make([]string, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txHashes[i] = tx.Hash().Hex()
+	}
+
+	summary := models.Block{
+		Number:            block.NumberU64(),
+		Hash:              block.Hash().Hex(),
+		ParentHash:        block.ParentHash().Hex(),
+		Timestamp:         block.Time(),
+		Miner:             block.Coinbase().Hex(),
+		GasLimit:          block.GasLimit(),
+		GasUsed:           block.GasUsed(),
+		TransactionHashes: txHashes,
+		LogCount:          len(logs),
+	}
+	if baseFee := block.BaseFee(); baseFee != nil {
+		summary.BaseFeePerGas = baseFee.String()
+	}
+
+	return summary, nil
+}
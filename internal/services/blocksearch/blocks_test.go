@@ -0,0 +1,106 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBlock(t *testing.T) {
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 2}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewServie(conf, logger, db)
+	ctx := context.Background()
+
+	var latest *types.Block
+	for number := uint64(1); number <= 3; number++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: number})
+		latest = types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), GasUsed: 21000, BaseFee: big.NewInt(7)}).
+			WithBody(types.Body{Transactions: types.Transactions{tx}})
+		txLogs := []*types.Log{{BlockNumber: number, TxHash: tx.Hash()}, {BlockNumber: number, TxHash: tx.Hash(), Index: 1}}
+		require.NoError(t, db.SetBlock(ctx, latest))
+		require.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), txLogs))
+	}
+
+	block, err := srv.GetBlock(ctx, BlockRef{Tag: models.BlockTagLatest})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), block.Number)
+	assert.Equal(t, latest.Hash().Hex(), block.Hash)
+	assert.Equal(t, uint64(21000), block.GasUsed)
+	assert.Equal(t, "7", block.BaseFeePerGas)
+	assert.Equal(t, []string{latest.Transactions()[0].Hash().Hex()}, block.TransactionHashes)
+	assert.Equal(t, 2, block.LogCount)
+
+	byHash, err := srv.GetBlockByHash(ctx, latest.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, block, byHash)
+
+	// the first block fell out of the window
+	_, err = srv.GetBlock(ctx, BlockRef{Number: 1})
+	var customErr *customerror.Error
+	require.ErrorAs(t, err, &customErr)
+	assert.Equal(t, customerror.ErrCodeNotFound, customErr.Code)
+
+	_, err = srv.GetBlockByHash(ctx, common.HexToHash("0x01"))
+	assert.Error(t, err)
+}
+
+func TestGetBlockTransactions(t *testing.T) {
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewServie(conf, logger, db)
+	ctx := context.Background()
+
+	txs := make(types.Transactions, 5)
+	txHashes := make([]string, len(txs))
+	for i := range txs {
+		txs[i] = types.NewTx(&types.LegacyTx{Nonce: uint64(i)})
+		txHashes[i] = txs[i].Hash().Hex()
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10)}).WithBody(types.Body{Transactions: txs})
+	require.NoError(t, db.SetBlock(ctx, block))
+	require.NoError(t, db.SetTransactionHashes(ctx, 10, txHashes))
+
+	var (
+		listed []string
+		cursor string
+	)
+	for {
+		page, err := srv.GetBlockTransactions(ctx, BlockRef{Number: 10}, Page{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Equal(t, block.Hash(), page.BlockHash)
+		listed = append(listed, page.TxHashes...)
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	assert.Equal(t, txHashes, listed)
+
+	// the cursor does not apply to the block replacing the one it was issued for
+	first, err := srv.GetBlockTransactions(ctx, BlockRef{Number: 10}, Page{Limit: 2})
+	require.NoError(t, err)
+	replacing := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10), Extra: []byte("canonical")})
+	require.NoError(t, db.SetBlock(ctx, replacing))
+	require.NoError(t, db.SetTransactionHashes(ctx, 10, []string{}))
+	_, err = srv.GetBlockTransactions(ctx, BlockRef{Number: 10}, Page{Cursor: first.Next})
+	assert.Error(t, err)
+
+	empty, err := srv.GetBlockTransactions(ctx, BlockRef{Number: 10}, Page{})
+	require.NoError(t, err)
+	assert.NotNil(t, empty.TxHashes)
+	assert.Empty(t, empty.TxHashes)
+}
@@ -0,0 +1,124 @@
+package blocksearch
+
+import (
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/logfilter"
+	"ethereum-tracker-app/pkg/topicaddress"
+	"slices"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockRef refers to a block either by its number or by a tag
+type BlockRef struct {
+	Number uint64
+	Tag    models.BlockTag // the number is ignored if set
+}
+
+// EventFilter narrows down the events of an address
+type EventFilter struct {
+	// Role selects the events emitted by the address, the events referring to the address in their indexed topics, or both.
+	// Empty means emitted
+	Role models.AddressRole
+	// FromBlock and ToBlock restrict the events to a range of blocks, inclusive. A nil bound leaves the range open on that side
+	FromBlock *BlockRef
+	ToBlock   *BlockRef
+	// BlockHash restricts the events to a single block, instead of a range
+	BlockHash *common.Hash
+	// Topics restricts the events by their topics, with the same semantics as eth_getLogs: the values of a position are OR-ed,
+	// the positions are AND-ed, and an empty position matches any topic
+	Topics [][]common.Hash
+}
+
+// match reports whether a log matches the block hash and the topics of the filter, the same as eth_getLogs
+func (f EventFilter) match(txLog types.Log) bool {
+	return logfilter.Match(ethereum.FilterQuery{BlockHash: f.BlockHash, Topics: f.Topics}, txLog)
+}
+
+// apply keeps the logs matching the filter
+func (f EventFilter) apply(logs []types.Log) []types.Log {
+	filtered := make([]types.Log, 0, len(logs))
+	for _, txLog := range logs {
+		// the logs removed by a reorganization may share the block number, but not the hash
+		if f.match(txLog) {
+			filtered = append(filtered, txLog)
+		}
+	}
+
+	return filtered
+}
+
+// Match reports whether a live log relates to an address by the role of the filter, and matches its block hash and topics. The
+// block bounds are not checked, since a live log is the newest anyway
+func (f EventFilter) Match(addressHex string, txLog types.Log) bool {
+	emitted := txLog.Address.Hex() == addressHex
+	switch f.Role {
+	case "", models.AddressRoleEmitter:
+		if !emitted {
+			return false
+		}
+	case models.AddressRoleParticipant:
+		if !slices.Contains(topicaddress.Parse(&txLog), addressHex) {
+			return false
+		}
+	default:
+		if !emitted && !slices.Contains(topicaddress.Parse(&txLog), addressHex) {
+			return false
+		}
+	}
+
+	return f.match(txLog)
+}
+
+// TransferFilter narrows down the token transfers of an address
+type TransferFilter struct {
+	// Direction selects the transfers received (in), sent (out) or both (any) by the address. Empty means any
+	Direction models.TransferDirection
+	// Token restricts the transfers to a token contract, if set
+	Token *common.Address
+}
+
+// apply keeps the transfers of an address matching the filter
+func (f TransferFilter) apply(addressHex string, transfers []models.Transfer) []models.Transfer {
+	filtered := make([]models.Transfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		if f.Direction == models.TransferDirectionIn && transfer.To != addressHex {
+			continue
+		}
+		if f.Direction == models.TransferDirectionOut && transfer.From != addressHex {
+			continue
+		}
+		if f.Token != nil && transfer.Token != f.Token.Hex() {
+			continue
+		}
+		filtered = append(filtered, transfer)
+	}
+
+	return filtered
+}
+
+// TransactionFilter narrows down the transactions of an address
+type TransactionFilter struct {
+	// Direction selects the transactions received (in), sent (out) or both (any) by the address. Empty means any. A contract
+	// creation is received by the created contract
+	Direction models.TransferDirection
+}
+
+// apply keeps the transactions of an address matching the filter
+func (f TransactionFilter) apply(addressHex string, transactions []models.AddressTransaction) []models.AddressTransaction {
+	filtered := make([]models.AddressTransaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		received := transaction.To == addressHex || transaction.ContractAddress == addressHex
+		if f.Direction == models.TransferDirectionIn && !received {
+			continue
+		}
+		if f.Direction == models.TransferDirectionOut && transaction.From != addressHex {
+			continue
+		}
+		filtered = append(filtered, transaction)
+	}
+
+	return filtered
+}
@@ -0,0 +1,63 @@
+package blocksearch
+
+import (
+	"ethereum-tracker-app/models"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFilterMatch(t *testing.T) {
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	wallet := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	txLog := types.Log{Address: token, Topics: []common.Hash{transfer, common.BytesToHash(wallet.Bytes())}}
+
+	type testCase struct {
+		name    string
+		address common.Address
+		filter  EventFilter
+		matched bool
+	}
+	for _, tt := range []testCase{
+		{name: "emitter", address: token, matched: true},
+		{name: "participant is not the emitter", address: wallet, matched: false},
+		{name: "participant", address: wallet, filter: EventFilter{Role: models.AddressRoleParticipant}, matched: true},
+		{name: "emitter is not a participant", address: token, filter: EventFilter{Role: models.AddressRoleParticipant}, matched: false},
+		{name: "any role", address: wallet, filter: EventFilter{Role: models.AddressRoleAny}, matched: true},
+		{name: "topics", address: token, filter: EventFilter{Topics: [][]common.Hash{{transfer}}}, matched: true},
+		{name: "other topics", address: token, filter: EventFilter{Topics: [][]common.Hash{{{}}}}, matched: false},
+		{name: "other block", address: token, filter: EventFilter{BlockHash: &transfer}, matched: false},
+	} {
+		assert.Equal(t, tt.matched, tt.filter.Match(tt.address.Hex(), txLog), tt.name)
+	}
+}
+
+func TestTransactionFilter(t *testing.T) {
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297").Hex()
+	other := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5").Hex()
+	transactions := []models.AddressTransaction{
+		{Hash: "sent", From: address, To: other},
+		{Hash: "received", From: other, To: address},
+		{Hash: "created", From: other, ContractAddress: address},
+	}
+
+	type testCase struct {
+		direction models.TransferDirection
+		hashes    []string
+	}
+	for _, tt := range []testCase{
+		{"", []string{"sent", "received", "created"}},
+		{models.TransferDirectionAny, []string{"sent", "received", "created"}},
+		{models.TransferDirectionIn, []string{"received", "created"}},
+		{models.TransferDirectionOut, []string{"sent"}},
+	} {
+		hashes := make([]string, 0)
+		for _, transaction := range (TransactionFilter{Direction: tt.direction}).apply(address, transactions) {
+			hashes = append(hashes, transaction.Hash)
+		}
+		assert.Equal(t, tt.hashes, hashes, "direction %q", tt.direction)
+	}
+}
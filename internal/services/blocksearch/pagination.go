@@ -0,0 +1,208 @@
+package blocksearch
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+
+	cursorLength   = 8 + 8 + 8 + common.HashLength
+	txCursorLength = common.HashLength + 8
+)
+
+// Page selects a page of the items. An empty cursor starts from the oldest item
+type Page struct {
+	Limit  int
+	Cursor string
+}
+
+// TransactionsPage is a page of the transaction hashes of a block, with the cursor of the next page when there are more
+type TransactionsPage struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHashes    []string
+	Next        string
+}
+
+// EventsPage is a page of the events, with the cursor of the next page when there are more events
+type EventsPage struct {
+	Events []types.Log
+	Next   string
+}
+
+// TransfersPage is a page of the token transfers, with the cursor of the next page when there are more transfers
+type TransfersPage struct {
+	Transfers []models.Transfer
+	Next      string
+}
+
+// AddressTransactionsPage is a page of the transactions of an address, with the cursor of the next page when there are more
+type AddressTransactionsPage struct {
+	Transactions []models.AddressTransaction
+	Next         string
+}
+
+// cursor is the position of the last item of a page. The items are ordered by (block number, log index, batch index), where
+// the batch index tells apart the transfers of the same batch log; the block hash breaks the tie between a removed log of
+// a reorged block and the canonical log at the same position. The transactions take their index in the block as the log index
+type cursor struct {
+	blockNumber uint64
+	logIndex    uint64
+	batchIndex  uint64
+	blockHash   common.Hash
+}
+
+func logCursor(txLog types.Log) cursor {
+	return cursor{blockNumber: txLog.BlockNumber, logIndex: uint64(txLog.Index), blockHash: txLog.BlockHash}
+}
+
+func transferCursor(transfer models.Transfer) cursor {
+	return cursor{
+		blockNumber: transfer.BlockNumber,
+		logIndex:    uint64(transfer.LogIndex),
+		batchIndex:  uint64(transfer.BatchIndex),
+		blockHash:   common.HexToHash(transfer.BlockHash),
+	}
+}
+
+func addressTxCursor(transaction models.AddressTransaction) cursor {
+	return cursor{
+		blockNumber: transaction.BlockNumber,
+		logIndex:    uint64(transaction.TransactionIndex),
+		blockHash:   common.HexToHash(transaction.BlockHash),
+	}
+}
+
+func (c cursor) encode() string {
+	raw := make([]byte, 0, cursorLength)
+	raw = binary.BigEndian.AppendUint64(raw, c.blockNumber)
+	raw = binary.BigEndian.AppendUint64(raw, c.logIndex)
+	raw = binary.BigEndian.AppendUint64(raw, c.batchIndex)
+	raw = append(raw, c.blockHash.Bytes()...)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != cursorLength {
+		return cursor{}, customerror.NewInvalidInputError("Invalid input: cursor is malformed", err)
+	}
+
+	return cursor{
+		blockNumber: binary.BigEndian.Uint64(raw[:8]),
+		logIndex:    binary.BigEndian.Uint64(raw[8:16]),
+		batchIndex:  binary.BigEndian.Uint64(raw[16:24]),
+		blockHash:   common.BytesToHash(raw[24:]),
+	}, nil
+}
+
+func (c cursor) less(other cursor) bool {
+	if c.blockNumber != other.blockNumber {
+		return c.blockNumber < other.blockNumber
+	}
+	if c.logIndex != other.logIndex {
+		return c.logIndex < other.logIndex
+	}
+	if c.batchIndex != other.batchIndex {
+		return c.batchIndex < other.batchIndex
+	}
+
+	return c.blockHash.Cmp(other.blockHash) < 0
+}
+
+// paginate gets the page of the items after the cursor. As the position of an item does not depend on the items ingested
+// after it, the pages stay stable while new blocks arrive
+func paginate[T any](items []T, position func(T) cursor, page Page) ([]T, string, error) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return position(items[i]).less(position(items[j]))
+	})
+
+	start := 0
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			return after.less(position(items[i]))
+		})
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	end := min(start+limit, len(items))
+
+	next := ""
+	if end < len(items) {
+		next = position(items[end-1]).encode()
+	}
+
+	return items[start:end], next, nil
+}
+
+// txCursor is the position of the first transaction of the next page in a block. The block hash tells apart the block
+// replacing it after a chain reorganization, whose transactions differ
+type txCursor struct {
+	blockHash common.Hash
+	index     uint64
+}
+
+func (c txCursor) encode() string {
+	raw := make([]byte, 0, txCursorLength)
+	raw = append(raw, c.blockHash.Bytes()...)
+	raw = binary.BigEndian.AppendUint64(raw, c.index)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTxCursor(encoded string) (txCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != txCursorLength {
+		return txCursor{}, customerror.NewInvalidInputError("Invalid input: cursor is malformed", err)
+	}
+
+	return txCursor{
+		blockHash: common.BytesToHash(raw[:common.HashLength]),
+		index:     binary.BigEndian.Uint64(raw[common.HashLength:]),
+	}, nil
+}
+
+// paginateTransactions gets the page of the transaction hashes of a block from the cursor
+func paginateTransactions(blockHash common.Hash, txHashes []string, page Page) ([]string, string, error) {
+	start := 0
+	if page.Cursor != "" {
+		from, err := decodeTxCursor(page.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if from.blockHash != blockHash {
+			return nil, "", customerror.NewInvalidInputError("Invalid input: cursor refers to a block replaced by a chain reorganization", nil)
+		}
+		start = int(min(from.index, uint64(len(txHashes))))
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	end := min(start+limit, len(txHashes))
+
+	next := ""
+	if end < len(txHashes) {
+		next = txCursor{blockHash: blockHash, index: uint64(end)}.encode()
+	}
+
+	return txHashes[start:end], next, nil
+}
@@ -0,0 +1,45 @@
+package blocksearch
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginate(t *testing.T) {
+	reorgedHash := common.HexToHash("0x02")
+	canonicalHash := common.HexToHash("0x01")
+	logs := []types.Log{
+		{BlockNumber: 11, Index: 0, BlockHash: canonicalHash},
+		{BlockNumber: 10, Index: 1, BlockHash: reorgedHash, Removed: true},
+		{BlockNumber: 10, Index: 1, BlockHash: canonicalHash},
+		{BlockNumber: 10, Index: 0, BlockHash: canonicalHash},
+	}
+
+	first, next, err := paginate(logs, logCursor, Page{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, uint(0), first[0].Index)
+	assert.Equal(t, canonicalHash, first[1].BlockHash)
+	require.NotEmpty(t, next)
+
+	// a block ingested meanwhile does not shift the next page
+	logs = append(logs, types.Log{BlockNumber: 12, Index: 0, BlockHash: canonicalHash})
+	second, next, err := paginate(logs, logCursor, Page{Limit: 2, Cursor: next})
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+	assert.True(t, second[0].Removed)
+	assert.Equal(t, uint64(11), second[1].BlockNumber)
+
+	last, next, err := paginate(logs, logCursor, Page{Limit: 2, Cursor: next})
+	require.NoError(t, err)
+	require.Len(t, last, 1)
+	assert.Equal(t, uint64(12), last[0].BlockNumber)
+	assert.Empty(t, next)
+
+	_, _, err = paginate(logs, logCursor, Page{Cursor: "not-a-cursor"})
+	assert.Error(t, err)
+}
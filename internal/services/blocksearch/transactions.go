@@ -0,0 +1,80 @@
+package blocksearch
+
+import (
+	"context"
+	"errors"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// GetTransaction gets an indexed transaction with its receipt and logs
+func (b *blockprocess) GetTransaction(ctx context.Context, hash common.Hash) (models.Transaction, error) {
+	block, index, err := b.db.GetBlockByTxHash(ctx, hash.Hex())
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	tx := block.Transactions()[index]
+
+	// the latest signer recovers the sender of every transaction type, from the chain id of the transaction itself
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return models.Transaction{}, customerror.NewOnChainDataRetrievalError("", err)
+	}
+
+	logs, err := b.db.GetLogsByTx(ctx, hash.Hex())
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transaction := models.Transaction{
+		Hash:             tx.Hash().Hex(),
+		BlockNumber:      block.NumberU64(),
+		BlockHash:        block.Hash().Hex(),
+		TransactionIndex: index,
+		From:             from.Hex(),
+		Value:            tx.Value().String(),
+		Nonce:            tx.Nonce(),
+		Type:             tx.Type(),
+		Gas:              tx.Gas(),
+		Logs:             models.NewEvents(logs),
+	}
+	if to := tx.To(); to != nil {
+		transaction.To = to.Hex()
+	}
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		transaction.GasPrice = tx.GasPrice().String()
+	} else {
+		transaction.MaxFeePerGas = tx.GasFeeCap().String()
+		transaction.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	}
+
+	receipt, err := b.db.GetReceipt(ctx, hash.Hex())
+	var customErr *customerror.Error
+	switch {
+	case err == nil:
+		transaction.Receipt = summarizeReceipt(receipt)
+	case !errors.As(err, &customErr) || customErr.Code != customerror.ErrCodeNotFound:
+		return models.Transaction{}, err
+	}
+
+	return transaction, nil
+}
+
+func summarizeReceipt(receipt *types.Receipt) *models.Receipt {
+	summary := &models.Receipt{
+		Status:            receipt.Status,
+		GasUsed:           receipt.GasUsed,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+	}
+	if receipt.EffectiveGasPrice != nil {
+		summary.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		summary.ContractAddress = receipt.ContractAddress.Hex()
+	}
+
+	return summary
+}
@@ -0,0 +1,71 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransaction(t *testing.T) {
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewServie(conf, logger, db)
+	ctx := context.Background()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+		ChainID: big.NewInt(1), Nonce: 3, To: &to, Value: big.NewInt(1000), Gas: 21000, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(30),
+	})
+	require.NoError(t, err)
+	pending, err := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(5)})
+	require.NoError(t, err)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10)}).
+		WithBody(types.Body{Transactions: types.Transactions{pending, tx}})
+	txLog := &types.Log{Address: to, BlockNumber: 10, BlockHash: block.Hash(), TxHash: tx.Hash(), TxIndex: 1}
+	require.NoError(t, db.SetBlock(ctx, block))
+	require.NoError(t, db.SetReceipt(ctx, &types.Receipt{
+		Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 42000, EffectiveGasPrice: big.NewInt(12),
+		TxHash: tx.Hash(), BlockNumber: big.NewInt(10), Logs: []*types.Log{txLog},
+	}))
+	require.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{txLog}))
+
+	transaction, err := srv.GetTransaction(ctx, tx.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, block.Hash().Hex(), transaction.BlockHash)
+	assert.Equal(t, 1, transaction.TransactionIndex)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey).Hex(), transaction.From)
+	assert.Equal(t, to.Hex(), transaction.To)
+	assert.Equal(t, "1000", transaction.Value)
+	assert.Equal(t, "30", transaction.MaxFeePerGas)
+	assert.Empty(t, transaction.GasPrice)
+	require.NotNil(t, transaction.Receipt)
+	assert.Equal(t, uint64(21000), transaction.Receipt.GasUsed)
+	assert.Equal(t, "12", transaction.Receipt.EffectiveGasPrice)
+	assert.Len(t, transaction.Logs, 1)
+
+	// the receipt of a transaction can still be retried
+	transaction, err = srv.GetTransaction(ctx, pending.Hash())
+	require.NoError(t, err)
+	assert.Nil(t, transaction.Receipt)
+	assert.Empty(t, transaction.To)
+	assert.Equal(t, "5", transaction.GasPrice)
+	assert.Empty(t, transaction.Logs)
+
+	_, err = srv.GetTransaction(ctx, common.HexToHash("0x01"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/pkg/customerror"
+
+	"github.com/pkg/errors"
+)
+
+// GetTransfersByAddress gets a page of the erc-20, erc-721 and erc-1155 token transfers of an address, which match the filter
+func (b *blockprocess) GetTransfersByAddress(ctx context.Context, address string, filter TransferFilter, page Page) (TransfersPage, error) {
+	transfers, err := b.db.GetTransfersByAddress(ctx, address)
+	if err != nil {
+		return TransfersPage{}, customerror.NewStorageError("", errors.Wrapf(err, "failed to get token transfers of address %s", address))
+	}
+
+	pageTransfers, next, err := paginate(filter.apply(address, transfers), transferCursor, page)
+	if err != nil {
+		return TransfersPage{}, err
+	}
+
+	return TransfersPage{Transfers: pageTransfers, Next: next}, nil
+}
+
+// GetTransfersByToken gets a page of the token transfers of a token contract
+func (b *blockprocess) GetTransfersByToken(ctx context.Context, token string, page Page) (TransfersPage, error) {
+	transfers, err := b.db.GetTransfersByToken(ctx, token)
+	if err != nil {
+		return TransfersPage{}, customerror.NewStorageError("", errors.Wrapf(err, "failed to get token transfers of token %s", token))
+	}
+
+	pageTransfers, next, err := paginate(transfers, transferCursor, page)
+	if err != nil {
+		return TransfersPage{}, err
+	}
+
+	return TransfersPage{Transfers: pageTransfers, Next: next}, nil
+}
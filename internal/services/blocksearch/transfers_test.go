@@ -0,0 +1,53 @@
+package blocksearch
+
+import (
+	"context"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransfersByToken(t *testing.T) {
+	conf := config.Config{}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewServie(conf, logger, db)
+	ctx := context.Background()
+
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7").Hex()
+	other := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F").Hex()
+	sender := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297").Hex()
+	recipient := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5").Hex()
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	require.NoError(t, db.SetBlock(ctx, block))
+	for i, tokenHex := range []string{token, other, token} {
+		require.NoError(t, db.SetTransfer(ctx, &models.Transfer{
+			Token: tokenHex, From: sender, To: recipient, BlockNumber: 1, BlockHash: block.Hash().Hex(), LogIndex: uint(i),
+		}))
+	}
+
+	page, err := srv.GetTransfersByToken(ctx, token, Page{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Transfers, 1)
+	assert.Equal(t, uint(0), page.Transfers[0].LogIndex)
+	require.NotEmpty(t, page.Next)
+
+	page, err = srv.GetTransfersByToken(ctx, token, Page{Limit: 1, Cursor: page.Next})
+	require.NoError(t, err)
+	require.Len(t, page.Transfers, 1)
+	assert.Equal(t, uint(2), page.Transfers[0].LogIndex)
+	assert.Empty(t, page.Next)
+
+	page, err = srv.GetTransfersByToken(ctx, recipient, Page{Limit: 1})
+	require.NoError(t, err)
+	assert.Empty(t, page.Transfers)
+}
@@ -0,0 +1,228 @@
+/*
+Live feed of the chain data, published as the synchronizer commits it to the storage, for the subscribers like the event streams.
+
+The publishers never block on a subscriber: each subscription has a buffer of SUBSCRIPTION_BUFFER_SIZE items, and a subscriber which
+falls further behind is dropped by closing its channel.
+
+Each published log gets the next number of the ingestion sequence, whether it comes from the live ingestion, the backfill, the retries
+or a reorganization removing it. The feed keeps a journal of the logs of the window of the recent blocks, so a dropped or reconnecting
+subscriber resumes after the sequence of the last log it received. The sequence starts from the start time of the service, so the
+sequences of a previous run are older than the journal.
+*/
+package chainfeed
+
+import (
+	"ethereum-tracker-app/cmd/config"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	defaultBufferSize   = 1024
+	defaultRecentBlocks = 50
+)
+
+type Service interface {
+	// PublishLogs publishes the logs of a block once they are stored, or the logs removed by a reorganization with Removed set.
+	// The logs of the head are live, the logs of the blocks behind it (startup, backfill and retries) are catching up
+	PublishLogs(logs []types.Log, live bool)
+	// PublishHead publishes the header of a canonical block once it is stored
+	PublishHead(header *types.Header)
+	SubscribeLogs() *Subscription[Log]
+	SubscribeHeads() *Subscription[*types.Header]
+	// LogsAfter gets the journaled logs published after a sequence, in order. A sequence older than the journal gets all of them
+	LogsAfter(sequence uint64) []Log
+	// Sequence gets the sequence of the last published log
+	Sequence() uint64
+}
+
+// Log is a published log with its number in the ingestion sequence
+type Log struct {
+	types.Log
+	Sequence uint64
+	Live     bool // false for the logs of the blocks behind the head, see PublishLogs
+}
+
+type feed struct {
+	logger       *log.Logger
+	recentBlocks uint64 // blocks of the window of the journal
+	logs         *broadcaster[Log]
+	heads        *broadcaster[*types.Header]
+	journalMu    sync.RWMutex
+	journal      []Log // in sequence order
+	sequence     uint64
+	latestNumber uint64
+}
+
+func NewService(config config.Config, logger *log.Logger) Service {
+	bufferSize := config.StreamConf.SubscriptionBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	recentBlocks := config.EthClientConf.NumberOfRecentBlocks
+	if recentBlocks <= 0 {
+		recentBlocks = defaultRecentBlocks
+	}
+
+	return &feed{
+		logger:       logger,
+		recentBlocks: uint64(recentBlocks),
+		logs:         newBroadcaster[Log](bufferSize),
+		heads:        newBroadcaster[*types.Header](bufferSize),
+		sequence:     uint64(time.Now().UnixNano()),
+	}
+}
+
+func (f *feed) PublishLogs(logs []types.Log, live bool) {
+	// journaled and broadcast under the same lock, so the subscribers receive the logs in sequence order
+	f.journalMu.Lock()
+	defer f.journalMu.Unlock()
+
+	published := make([]Log, len(logs))
+	for i, txLog := range logs {
+		f.sequence++
+		published[i] = Log{Log: txLog, Sequence: f.sequence, Live: live}
+	}
+	f.journal = append(f.journal, published...)
+
+	if dropped := f.logs.publish(published...); dropped > 0 {
+		f.logger.Printf("dropped %d log subscribers falling behind", dropped)
+	}
+}
+
+func (f *feed) PublishHead(header *types.Header) {
+	f.pruneJournal(header.Number.Uint64())
+
+	if dropped := f.heads.publish(header); dropped > 0 {
+		f.logger.Printf("dropped %d head subscribers falling behind", dropped)
+	}
+}
+
+func (f *feed) SubscribeLogs() *Subscription[Log] {
+	return f.logs.subscribe()
+}
+
+func (f *feed) LogsAfter(sequence uint64) []Log {
+	f.journalMu.RLock()
+	defer f.journalMu.RUnlock()
+
+	// the journal is in sequence order
+	first := len(f.journal)
+	for first > 0 && f.journal[first-1].Sequence > sequence {
+		first--
+	}
+
+	return append([]Log(nil), f.journal[first:]...)
+}
+
+func (f *feed) Sequence() uint64 {
+	f.journalMu.RLock()
+	defer f.journalMu.RUnlock()
+
+	return f.sequence
+}
+
+// pruneJournal removes the logs of the blocks falling out of the window of the recent blocks, as the new head comes
+func (f *feed) pruneJournal(number uint64) {
+	f.journalMu.Lock()
+	defer f.journalMu.Unlock()
+
+	if number <= f.latestNumber {
+		return
+	}
+	f.latestNumber = number
+	if number < f.recentBlocks {
+		return
+	}
+
+	kept := f.journal[:0]
+	for _, txLog := range f.journal {
+		if txLog.BlockNumber > number-f.recentBlocks {
+			kept = append(kept, txLog)
+		}
+	}
+	clear(f.journal[len(kept):])
+	f.journal = kept
+}
+
+func (f *feed) SubscribeHeads() *Subscription[*types.Header] {
+	return f.heads.subscribe()
+}
+
+// Subscription receives the published items in order, until it is unsubscribed or dropped for falling behind
+type Subscription[T any] struct {
+	ch          chan T
+	broadcaster *broadcaster[T]
+}
+
+// C gets the channel of the items, which is closed once the subscription ends
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Unsubscribe ends the subscription. It is safe to call more than once, and after the subscription is dropped
+func (s *Subscription[T]) Unsubscribe() {
+	s.broadcaster.remove(s)
+}
+
+// broadcaster fans out the published items to its subscribers without blocking
+type broadcaster[T any] struct {
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[*Subscription[T]]struct{}
+}
+
+func newBroadcaster[T any](bufferSize int) *broadcaster[T] {
+	return &broadcaster[T]{
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription[T]]struct{}),
+	}
+}
+
+func (b *broadcaster[T]) subscribe() *Subscription[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription[T]{ch: make(chan T, b.bufferSize), broadcaster: b}
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// publish sends the items to every subscriber, dropping the ones whose buffer is full. It returns the number of dropped subscribers
+func (b *broadcaster[T]) publish(items ...T) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := 0
+	for sub := range b.subscribers {
+		for _, item := range items {
+			select {
+			case sub.ch <- item:
+				continue
+			default:
+			}
+
+			delete(b.subscribers, sub)
+			close(sub.ch)
+			dropped++
+			break
+		}
+	}
+
+	return dropped
+}
+
+func (b *broadcaster[T]) remove(sub *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
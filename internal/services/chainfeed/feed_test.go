@@ -0,0 +1,83 @@
+package chainfeed
+
+import (
+	"ethereum-tracker-app/cmd/config"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeed(t *testing.T) {
+	conf := config.Config{StreamConf: config.StreamConf{SubscriptionBufferSize: 2}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	srv := NewService(conf, logger)
+
+	fast := srv.SubscribeLogs()
+	slow := srv.SubscribeLogs()
+	gone := srv.SubscribeLogs()
+	gone.Unsubscribe()
+	gone.Unsubscribe()
+	_, ok := <-gone.C()
+	assert.False(t, ok, "an unsubscribed channel is closed")
+
+	srv.PublishLogs([]types.Log{{BlockNumber: 1, Index: 0}, {BlockNumber: 1, Index: 1}}, true)
+	for i := uint(0); i < 2; i++ {
+		received := <-fast.C()
+		assert.Equal(t, i, received.Index, "the logs are received in order")
+	}
+
+	// the slow subscriber still holds the first two logs, its buffer is full
+	srv.PublishLogs([]types.Log{{BlockNumber: 2, Index: 0}}, true)
+	assert.Equal(t, uint64(2), (<-fast.C()).BlockNumber)
+	var received []types.Log
+	for txLog := range slow.C() {
+		received = append(received, txLog.Log)
+	}
+	assert.Len(t, received, 2, "the buffered logs are kept, then the channel of the dropped subscriber is closed")
+	slow.Unsubscribe()
+
+	heads := srv.SubscribeHeads()
+	defer heads.Unsubscribe()
+	srv.PublishHead(&types.Header{Number: big.NewInt(1)})
+	assert.NotNil(t, <-heads.C())
+}
+
+func TestLogsAfter(t *testing.T) {
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 2}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	srv := NewService(conf, logger)
+
+	assert.Empty(t, srv.LogsAfter(0), "nothing is published yet")
+
+	sub := srv.SubscribeLogs()
+	defer sub.Unsubscribe()
+	srv.PublishLogs([]types.Log{{BlockNumber: 5}, {BlockNumber: 5, Index: 1}}, true)
+	first, second := <-sub.C(), <-sub.C()
+	assert.Equal(t, first.Sequence+1, second.Sequence, "the logs are numbered in publication order")
+
+	// a backfilled block and a removal are published after the live logs, whatever their block numbers
+	srv.PublishLogs([]types.Log{{BlockNumber: 3}}, false)
+	srv.PublishLogs([]types.Log{{BlockNumber: 5, Removed: true}}, true)
+
+	journaled := srv.LogsAfter(first.Sequence)
+	assert.Len(t, journaled, 3)
+	assert.Equal(t, second.Sequence, journaled[0].Sequence)
+	assert.Equal(t, uint64(3), journaled[1].BlockNumber)
+	assert.False(t, journaled[1].Live)
+	assert.True(t, journaled[2].Live)
+	assert.True(t, journaled[2].Removed)
+	assert.Len(t, srv.LogsAfter(0), 4, "a sequence older than the journal gets all of it")
+	assert.Empty(t, srv.LogsAfter(journaled[2].Sequence))
+	assert.Equal(t, journaled[2].Sequence, srv.Sequence())
+
+	srv.PublishHead(&types.Header{Number: big.NewInt(6)})
+	journaled = srv.LogsAfter(0)
+	assert.Len(t, journaled, 3, "the logs out of the window of the recent blocks are pruned")
+	for _, txLog := range journaled {
+		assert.Equal(t, uint64(5), txLog.BlockNumber)
+	}
+}
@@ -0,0 +1,139 @@
+package rpcfacade
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// marshalBlock encodes a block the same as eth_getBlockByNumber of go-ethereum. The transactions are the hashes, or the whole
+// transactions if fullTx is set
+func marshalBlock(block *types.Block, fullTx bool) (map[string]interface{}, error) {
+	fields, err := toFields(block.Header())
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot encode block %d", block.NumberU64())
+	}
+	// the fields of the forks not activated at the block are absent, rather than null
+	for name, value := range fields {
+		if value == nil {
+			delete(fields, name)
+		}
+	}
+
+	transactions := make([]interface{}, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		if !fullTx {
+			transactions[i] = tx.Hash()
+			continue
+		}
+		if transactions[i], err = marshalTransaction(tx, block, i); err != nil {
+			return nil, err
+		}
+	}
+
+	uncles := make([]common.Hash, len(block.Uncles()))
+	for i, uncle := range block.Uncles() {
+		uncles[i] = uncle.Hash()
+	}
+
+	fields["size"] = hexutil.Uint64(block.Size())
+	fields["transactions"] = transactions
+	fields["uncles"] = uncles
+	if withdrawals := block.Withdrawals(); withdrawals != nil {
+		fields["withdrawals"] = withdrawals
+	}
+
+	return fields, nil
+}
+
+// marshalTransaction encodes a transaction of a block the same as go-ethereum, with its sender and its position in the block
+func marshalTransaction(tx *types.Transaction, block *types.Block, index int) (map[string]interface{}, error) {
+	fields, err := toFields(tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot encode transaction %v", tx.Hash())
+	}
+
+	// the latest signer recovers the sender of every transaction type, from the chain id of the transaction itself
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot recover the sender of transaction %v", tx.Hash())
+	}
+
+	fields["blockHash"] = block.Hash()
+	fields["blockNumber"] = (*hexutil.Big)(block.Number())
+	fields["transactionIndex"] = hexutil.Uint64(index)
+	fields["from"] = from
+	if tx.Type() != types.LegacyTxType && tx.Type() != types.AccessListTxType && block.BaseFee() != nil {
+		fields["gasPrice"] = (*hexutil.Big)(effectiveGasPrice(tx, block.BaseFee()))
+	}
+
+	return fields, nil
+}
+
+// marshalReceipt encodes the receipt of a transaction the same as eth_getTransactionReceipt of go-ethereum
+func marshalReceipt(receipt *types.Receipt, block *types.Block, index int, logs []types.Log) (map[string]interface{}, error) {
+	tx := block.Transactions()[index]
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot recover the sender of transaction %v", tx.Hash())
+	}
+
+	fields := map[string]interface{}{
+		"blockHash":         block.Hash(),
+		"blockNumber":       hexutil.Uint64(block.NumberU64()),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(index),
+		"from":              from,
+		"to":                tx.To(),
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              logs,
+		"logsBloom":         receipt.Bloom,
+		"type":              hexutil.Uint(tx.Type()),
+		"effectiveGasPrice": (*hexutil.Big)(receipt.EffectiveGasPrice),
+	}
+	if len(receipt.PostState) > 0 {
+		fields["root"] = hexutil.Bytes(receipt.PostState)
+	} else {
+		fields["status"] = hexutil.Uint(receipt.Status)
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		fields["contractAddress"] = receipt.ContractAddress
+	}
+	if tx.Type() == types.BlobTxType {
+		fields["blobGasUsed"] = hexutil.Uint64(receipt.BlobGasUsed)
+		fields["blobGasPrice"] = (*hexutil.Big)(receipt.BlobGasPrice)
+	}
+
+	return fields, nil
+}
+
+// effectiveGasPrice gets the gas price paid by a dynamic fee transaction: the base fee with the tip, capped by the fee cap
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	price := new(big.Int).Add(baseFee, tx.GasTipCap())
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		return new(big.Int).Set(tx.GasFeeCap())
+	}
+
+	return price
+}
+
+// toFields gets the json fields of a value by its own json encoding
+func toFields(value interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
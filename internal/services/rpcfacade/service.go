@@ -0,0 +1,230 @@
+/*
+Ethereum JSON-RPC facade over the local index, so the json-rpc clients like ethers or web3 can use the service as a cache of the node.
+
+The answers follow the json-rpc api of go-ethereum. The filters of eth_getLogs have the semantics of go-ethereum's FilterQuery, and a block
+range which is not fully indexed, e.g. older than the window of the recent blocks, is rejected instead of answering it partially.
+*/
+package rpcfacade
+
+import (
+	"context"
+	"errors"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"ethereum-tracker-app/pkg/logfilter"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const maxTopics = 4 // the same as go-ethereum
+
+type Service interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	GetLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error)
+	GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error)
+}
+
+type storageService interface {
+	GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	GetBlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	GetLogsByBlock(ctx context.Context, number uint64) ([]types.Log, error)
+	GetLogsByTx(ctx context.Context, txHashHex string) ([]types.Log, error)
+	GetReceipt(ctx context.Context, txHashHex string) (*types.Receipt, error)
+	GetBlockByTxHash(ctx context.Context, txHashHex string) (*types.Block, int, error)
+	GetBlockTag(ctx context.Context, tag models.BlockTag) (uint64, error)
+}
+
+type facade struct {
+	config config.Config
+	logger *log.Logger
+	db     storageService
+}
+
+func NewService(config config.Config, logger *log.Logger, db storageService) Service {
+	return &facade{
+		config: config,
+		logger: logger,
+		db:     db,
+	}
+}
+
+// BlockNumber gets the number of the latest indexed block
+func (f *facade) BlockNumber(ctx context.Context) (uint64, error) {
+	return f.db.GetBlockTag(ctx, models.BlockTagLatest)
+}
+
+// GetLogs gets the logs matching a filter query, either in a single block by its hash or in a range of blocks. The open bounds of
+// the range are the latest block
+func (f *facade) GetLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if len(query.Topics) > maxTopics {
+		return nil, customerror.NewInvalidInputError("exceed max topics", nil)
+	}
+
+	if query.BlockHash != nil {
+		if query.FromBlock != nil || query.ToBlock != nil {
+			return nil, customerror.NewInvalidInputError("cannot specify both BlockHash and FromBlock/ToBlock, choose one or the other", nil)
+		}
+
+		block, err := f.db.GetBlockByHash(ctx, *query.BlockHash)
+		if err != nil {
+			return nil, customerror.NewNotFoundError("unknown block", err)
+		}
+		return f.blockLogs(ctx, block, query)
+	}
+
+	head, err := f.db.GetBlockTag(ctx, models.BlockTagLatest)
+	if err != nil {
+		return nil, err
+	}
+
+	fromBlock, toBlock := head, head
+	if query.FromBlock != nil {
+		if fromBlock, err = f.resolveBlockNumber(ctx, rpc.BlockNumber(query.FromBlock.Int64())); err != nil {
+			return nil, err
+		}
+	}
+	if query.ToBlock != nil {
+		if toBlock, err = f.resolveBlockNumber(ctx, rpc.BlockNumber(query.ToBlock.Int64())); err != nil {
+			return nil, err
+		}
+	}
+	if fromBlock > toBlock {
+		return nil, customerror.NewInvalidInputError("invalid block range params", nil)
+	}
+
+	// the node has no logs after its head either
+	logs := make([]types.Log, 0)
+	for number := fromBlock; number <= min(toBlock, head); number++ {
+		block, err := f.db.GetBlockByNumber(ctx, number)
+		if number < f.windowStart(head) {
+			return nil, customerror.NewOutOfRangeError(fmt.Sprintf("block range %d-%d is outside the indexed window %d-%d", fromBlock, toBlock, f.windowStart(head), head), err)
+		}
+		if err != nil || !f.isIndexed(ctx, block) {
+			return nil, customerror.NewOutOfRangeError(fmt.Sprintf("block %d of the range %d-%d is not indexed yet", number, fromBlock, toBlock), err)
+		}
+
+		blockLogs, err := f.blockLogs(ctx, block, query)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, blockLogs...)
+	}
+
+	return logs, nil
+}
+
+// GetBlockByNumber gets a block in the json format of the node, with the hashes or the whole of its transactions. A block after the
+// latest one is null, like on the node
+func (f *facade) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	head, err := f.db.GetBlockTag(ctx, models.BlockTagLatest)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := f.resolveBlockNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if resolved > head {
+		return nil, nil
+	}
+
+	block, err := f.db.GetBlockByNumber(ctx, resolved)
+	if err != nil {
+		return nil, customerror.NewOutOfRangeError(fmt.Sprintf("block %d is outside the indexed window %d-%d", resolved, f.windowStart(head), head), err)
+	}
+
+	return marshalBlock(block, fullTx)
+}
+
+// GetTransactionReceipt gets the receipt of a transaction in the json format of the node. The receipt of an unknown transaction,
+// or of a transaction whose receipt is being retried, is null
+func (f *facade) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	block, index, err := f.db.GetBlockByTxHash(ctx, hash.Hex())
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := f.db.GetReceipt(ctx, hash.Hex())
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	logs, err := f.db.GetLogsByTx(ctx, hash.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalReceipt(receipt, block, index, logs)
+}
+
+// blockLogs gets the logs of a block matching the addresses and the topics of a filter query
+func (f *facade) blockLogs(ctx context.Context, block *types.Block, query ethereum.FilterQuery) ([]types.Log, error) {
+	logs, err := f.db.GetLogsByBlock(ctx, block.NumberU64())
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]types.Log, 0, len(logs))
+	for _, txLog := range logs {
+		if logfilter.Match(query, txLog) {
+			matched = append(matched, txLog)
+		}
+	}
+
+	return matched, nil
+}
+
+// isIndexed reports whether the receipts of all the transactions of a block are stored, so the logs of the block are complete
+func (f *facade) isIndexed(ctx context.Context, block *types.Block) bool {
+	for _, tx := range block.Transactions() {
+		if _, err := f.db.GetReceipt(ctx, tx.Hash().Hex()); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// windowStart gets the oldest block number of the window of the recent blocks, given the latest block
+func (f *facade) windowStart(head uint64) uint64 {
+	windowSize := uint64(f.config.EthClientConf.NumberOfRecentBlocks)
+	if windowSize == 0 || head < windowSize {
+		return 1
+	}
+
+	return head - windowSize + 1
+}
+
+// resolveBlockNumber gets the number of a json-rpc block number, looking up the tags in the storage. The pending block is the latest one
+func (f *facade) resolveBlockNumber(ctx context.Context, number rpc.BlockNumber) (uint64, error) {
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return f.db.GetBlockTag(ctx, models.BlockTagLatest)
+	case rpc.SafeBlockNumber:
+		return f.db.GetBlockTag(ctx, models.BlockTagSafe)
+	case rpc.FinalizedBlockNumber:
+		return f.db.GetBlockTag(ctx, models.BlockTagFinalized)
+	}
+	if number < 0 {
+		return 0, customerror.NewInvalidInputError(fmt.Sprintf("invalid block number %d", number), nil)
+	}
+
+	return uint64(number), nil
+}
+
+func isNotFound(err error) bool {
+	var customErr *customerror.Error
+	return errors.As(err, &customErr) && customErr.Code == customerror.ErrCodeNotFound
+}
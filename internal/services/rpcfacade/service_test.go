@@ -0,0 +1,123 @@
+package rpcfacade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/pkg/customerror"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFacade(t *testing.T) {
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 3}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	db := inmemorydb.NewInmemortDBService(conf, logger)
+	srv := NewService(conf, logger, db)
+	ctx := context.Background()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	transferTopic := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+
+	// blocks 2 to 5 are ingested, block 2 falls out of the window and the receipt of block 5 is not retrieved yet
+	txs := make(map[uint64]*types.Transaction)
+	for number := uint64(2); number <= 5; number++ {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID: big.NewInt(1), Nonce: number, To: &token, Gas: 60000, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(30),
+		})
+		require.NoError(t, err)
+		txs[number] = tx
+
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), BaseFee: big.NewInt(10)}).
+			WithBody(types.Body{Transactions: types.Transactions{tx}})
+		require.NoError(t, db.SetBlock(ctx, block))
+		if number == 5 {
+			continue
+		}
+
+		txLog := &types.Log{Address: token, Topics: []common.Hash{transferTopic, {}}, BlockNumber: number, BlockHash: block.Hash(), TxHash: tx.Hash()}
+		require.NoError(t, db.SetReceipt(ctx, &types.Receipt{
+			Status: types.ReceiptStatusSuccessful, GasUsed: 50000, TxHash: tx.Hash(), BlockNumber: block.Number(), Logs: []*types.Log{txLog},
+		}))
+		require.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{txLog}))
+		require.NoError(t, db.SetLogByAddress(ctx, token.Hex(), txLog))
+	}
+
+	head, err := srv.BlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), head)
+
+	type testCase struct {
+		name  string
+		query ethereum.FilterQuery
+		logs  int
+		code  customerror.ErrorCode
+	}
+	for _, tt := range []testCase{
+		{name: "range in the window", query: ethereum.FilterQuery{FromBlock: big.NewInt(3), ToBlock: big.NewInt(4)}, logs: 2},
+		{name: "address", query: ethereum.FilterQuery{FromBlock: big.NewInt(3), ToBlock: big.NewInt(4), Addresses: []common.Address{{}}}},
+		{name: "topics", query: ethereum.FilterQuery{FromBlock: big.NewInt(3), ToBlock: big.NewInt(4), Topics: [][]common.Hash{{transferTopic}, {}}}, logs: 2},
+		{name: "more topics than the log", query: ethereum.FilterQuery{FromBlock: big.NewInt(3), ToBlock: big.NewInt(4), Topics: [][]common.Hash{{}, {}, {}}}},
+		{name: "evicted block", query: ethereum.FilterQuery{FromBlock: big.NewInt(2), ToBlock: big.NewInt(4)}, code: customerror.ErrCodeOutOfRange},
+		{name: "block being processed", query: ethereum.FilterQuery{FromBlock: big.NewInt(4)}, code: customerror.ErrCodeOutOfRange},
+		{name: "after the head", query: ethereum.FilterQuery{FromBlock: big.NewInt(6), ToBlock: big.NewInt(9)}},
+		{name: "reversed range", query: ethereum.FilterQuery{FromBlock: big.NewInt(4), ToBlock: big.NewInt(3)}, code: customerror.ErrCodeInvalidInput},
+		{name: "hash and range", query: ethereum.FilterQuery{BlockHash: &common.Hash{}, FromBlock: big.NewInt(3)}, code: customerror.ErrCodeInvalidInput},
+	} {
+		logs, err := srv.GetLogs(ctx, tt.query)
+		if tt.code != 0 {
+			var customErr *customerror.Error
+			require.True(t, errors.As(err, &customErr), tt.name)
+			assert.Equal(t, tt.code, customErr.Code, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Len(t, logs, tt.logs, tt.name)
+	}
+
+	block, err := srv.GetBlockByNumber(ctx, rpc.LatestBlockNumber, true)
+	require.NoError(t, err)
+	encoded, err := json.Marshal(block)
+	require.NoError(t, err)
+	var decoded struct {
+		Number       string `json:"number"`
+		Transactions []struct {
+			From     common.Address `json:"from"`
+			GasPrice string         `json:"gasPrice"`
+		} `json:"transactions"`
+	}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, "0x5", decoded.Number)
+	require.Len(t, decoded.Transactions, 1)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), decoded.Transactions[0].From)
+	assert.Equal(t, "0xc", decoded.Transactions[0].GasPrice, "the base fee with the tip")
+
+	block, err = srv.GetBlockByNumber(ctx, rpc.BlockNumber(6), false)
+	require.NoError(t, err)
+	assert.Nil(t, block, "a block after the head is null")
+	_, err = srv.GetBlockByNumber(ctx, rpc.BlockNumber(2), false)
+	assert.Error(t, err, "an evicted block is out of the window")
+
+	receipt, err := srv.GetTransactionReceipt(ctx, txs[4].Hash())
+	require.NoError(t, err)
+	assert.Equal(t, txs[4].Hash(), receipt["transactionHash"])
+	assert.Len(t, receipt["logs"], 1)
+	receipt, err = srv.GetTransactionReceipt(ctx, txs[5].Hash())
+	require.NoError(t, err)
+	assert.Nil(t, receipt, "the receipt being retrieved is null")
+}
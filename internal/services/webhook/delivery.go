@@ -0,0 +1,400 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"ethereum-tracker-app/models"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	maxPendingEvents   = 10000 // per webhook, the newer events are read from the storage once the queue is drained
+	maxBatchEvents     = 100
+	maxDeliveryLog     = 100 // the latest attempts kept per webhook
+	maxResponseBody    = 1 << 16
+	cursorSaveInterval = 10 * time.Second // of an idle webhook, whose delivered block follows the checkpoint
+
+	IDHeader        = "X-Webhook-Id"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// hook is a registered webhook with its queue of events. The events at the head of the queue are the batch being delivered, which
+// is retried with the same delivery id until it is acknowledged. The events not queued, as the queue was full or the webhook was
+// restarted, are read from the storage from the overflow block on once the queue is drained
+type hook struct {
+	query       ethereum.FilterQuery
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	wake        chan struct{} // signals the worker of the new events, or of the webhook enabled again
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	storeMu sync.Mutex // serializes the writes of the webhook to the storage
+	deleted bool       // guarded by storeMu
+
+	mu           sync.Mutex
+	webhook      models.Webhook
+	pending      []types.Log
+	overflow     bool   // events are not queued, they are read from the storage once the queue is drained
+	overflowFrom uint64 // the oldest block of the events not queued, math.MaxUint64 if none since the read started
+	batch        int    // events of the delivery in progress, zero if none
+	deliveryID   string
+	attempts     int // attempts of the delivery in progress
+	sequence     uint64
+	deliveries   []models.WebhookDelivery // the latest attempts, the oldest first
+}
+
+func newHook(ctx context.Context, webhook models.Webhook, baseBackoff, maxBackoff time.Duration) *hook {
+	ctx, cancel := context.WithCancel(ctx)
+	return &hook{
+		query:       ethereum.FilterQuery{Addresses: webhook.Addresses, Topics: webhook.Topics},
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		wake:        make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		webhook:     webhook,
+	}
+}
+
+// state gets a copy of the webhook
+func (h *hook) state() models.Webhook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.webhook
+}
+
+// enqueue queues an event of the webhook. A disabled webhook keeps queueing, and delivers its queue once it is enabled again.
+// Once the queue is full, the events are read from the storage after the queue is drained instead
+func (h *hook) enqueue(txLog types.Log) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.overflow || len(h.pending) >= maxPendingEvents {
+		h.overflowAtLocked(txLog.BlockNumber)
+		return
+	}
+	h.pending = append(h.pending, txLog)
+	h.signal()
+}
+
+// overflowAt makes the events from a block on be read from the storage once the queue is drained
+func (h *hook) overflowAt(blockNumber uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.overflowAtLocked(blockNumber)
+}
+
+// overflowAtLocked is overflowAt with the lock held
+func (h *hook) overflowAtLocked(blockNumber uint64) {
+	if !h.overflow {
+		h.overflow = true
+		h.overflowFrom = blockNumber
+	}
+	h.overflowFrom = min(h.overflowFrom, blockNumber)
+	h.signal()
+}
+
+// startRead gets the block from which the events not queued are read from the storage, once the queue is drained. It reports false
+// if there is nothing to read yet. The events not queued during the read are read by the next one
+func (h *hook) startRead() (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.overflow || len(h.pending) != 0 {
+		return 0, false
+	}
+
+	fromBlock := h.overflowFrom
+	h.overflowFrom = math.MaxUint64
+	return fromBlock, true
+}
+
+// endRead queues the events read from the storage, as many whole blocks as the queue holds, and at least one. The rest is read
+// again by the next read, and so are the events not queued during the read. A failed read is attempted again
+func (h *hook) endRead(fromBlock uint64, logs []types.Log, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err != nil {
+		h.overflowFrom = min(h.overflowFrom, fromBlock)
+		return
+	}
+
+	queued := min(len(logs), maxPendingEvents)
+	for queued > 0 && queued < len(logs) && logs[queued].BlockNumber == logs[queued-1].BlockNumber {
+		queued++
+	}
+	h.pending = append(h.pending, logs[:queued]...)
+	if queued < len(logs) {
+		h.overflowFrom = min(h.overflowFrom, logs[queued].BlockNumber)
+	}
+	if h.overflowFrom == math.MaxUint64 {
+		h.overflow = false
+	}
+}
+
+// advance sets the delivered block to a block, or before the oldest event queued or to be read from the storage. It reports whether
+// the delivered block changed
+func (h *hook) advance(cursor uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, txLog := range h.pending {
+		cursor = min(cursor, blockBefore(txLog.BlockNumber))
+	}
+	if h.overflow {
+		cursor = min(cursor, blockBefore(h.overflowFrom))
+	}
+	if cursor == h.webhook.DeliveredBlock {
+		return false
+	}
+
+	h.webhook.DeliveredBlock = cursor
+	return true
+}
+
+// next gets the batch to deliver, with its delivery id and the number of the attempt. It reports false if the webhook is disabled
+// or has nothing to deliver
+func (h *hook) next() ([]types.Log, string, int, models.Webhook, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.webhook.Enabled || len(h.pending) == 0 {
+		return nil, "", 0, models.Webhook{}, false
+	}
+
+	if h.batch == 0 {
+		h.sequence++
+		h.batch = min(len(h.pending), maxBatchEvents)
+		h.deliveryID = fmt.Sprintf("%s-%d", h.webhook.ID, h.sequence)
+		h.attempts = 0
+	}
+	h.attempts++
+
+	return slices.Clone(h.pending[:h.batch]), h.deliveryID, h.attempts, h.webhook, true
+}
+
+// record records an attempt of the delivery in progress. A successful one acknowledges the batch; a failed one counts towards
+// the failures disabling the webhook, if maxFailures is positive. It reports whether the webhook is disabled by the attempt
+func (h *hook) record(delivery models.WebhookDelivery, maxFailures int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.deliveries = append(h.deliveries, delivery)
+	if len(h.deliveries) > maxDeliveryLog {
+		h.deliveries = slices.Delete(h.deliveries, 0, len(h.deliveries)-maxDeliveryLog)
+	}
+
+	if delivery.Success {
+		h.pending = slices.Delete(h.pending, 0, h.batch)
+		h.batch = 0
+		h.webhook.ConsecutiveFailures = 0
+		return false
+	}
+
+	h.webhook.ConsecutiveFailures++
+	if maxFailures <= 0 || h.webhook.ConsecutiveFailures < maxFailures {
+		return false
+	}
+
+	h.webhook.Enabled = false
+	h.webhook.DisabledReason = fmt.Sprintf("disabled after %d consecutive failed attempts, the last one: %s", h.webhook.ConsecutiveFailures, delivery.Error)
+	return true
+}
+
+// enable enables the webhook again, resuming the delivery of its queue
+func (h *hook) enable() models.Webhook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.webhook.Enabled = true
+	h.webhook.ConsecutiveFailures = 0
+	h.webhook.DisabledReason = ""
+	h.signal()
+
+	return h.webhook
+}
+
+// stop ends the worker of the webhook, dropping its queue
+func (h *hook) stop() {
+	h.cancel()
+}
+
+// deliveryLog gets the latest delivery attempts, the newest first
+func (h *hook) deliveryLog() []models.WebhookDelivery {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	deliveries := slices.Clone(h.deliveries)
+	slices.Reverse(deliveries)
+
+	return deliveries
+}
+
+// signal wakes the worker up without blocking. The caller must hold the lock
+func (h *hook) signal() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// backoff doubles the wait per failed attempt of a delivery, up to the maximum backoff
+func (h *hook) backoff(attempts int) time.Duration {
+	if attempts > 30 {
+		return h.maxBackoff
+	}
+
+	return min(h.baseBackoff<<(attempts-1), h.maxBackoff)
+}
+
+// deliverLoop delivers the queue of a webhook batch by batch, retrying a failed batch after its backoff, until the webhook is deleted.
+// A drained queue is refilled from the storage with the events which were not queued
+func (d *dispatcher) deliverLoop(h *hook) {
+	cursorTicker := time.NewTicker(cursorSaveInterval)
+	defer cursorTicker.Stop()
+
+	for {
+		if fromBlock, ok := h.startRead(); ok {
+			d.logger.Printf("webhook %s reads its events from block %d from the storage", h.state().ID, fromBlock)
+			logs, err := d.readStored(h.ctx, h, fromBlock)
+			h.endRead(fromBlock, logs, err)
+			if err != nil {
+				d.logger.Printf("failed to read the events of webhook %s from the storage: %v", h.state().ID, err)
+				select {
+				case <-h.ctx.Done():
+					return
+				case <-time.After(h.maxBackoff):
+				}
+			}
+			continue
+		}
+
+		batch, deliveryID, attempts, webhook, ok := h.next()
+		if !ok {
+			select {
+			case <-h.ctx.Done():
+				return
+			case <-h.wake:
+			case <-cursorTicker.C:
+				d.saveCursor(h)
+			}
+			continue
+		}
+
+		delivery := d.deliver(h.ctx, webhook, deliveryID, batch)
+		delivery.Attempt = attempts
+		if h.ctx.Err() != nil {
+			return // deleted, or shut down, meanwhile
+		}
+
+		if h.record(delivery, d.config.WebhookConf.MaxFailures) {
+			d.logger.Printf("webhook %s is disabled after %d consecutive failed attempts", webhook.ID, attempts)
+			if _, err := d.store(h.ctx, h); err != nil {
+				d.logger.Printf("failed to store the disabled webhook %s: %v", webhook.ID, err)
+			}
+			continue
+		}
+		if delivery.Success {
+			d.saveCursor(h)
+			continue
+		}
+
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-time.After(h.backoff(attempts)):
+		}
+	}
+}
+
+// deliver posts a batch of events to a webhook, signed if the webhook has a secret. Only a 2xx answer acknowledges the batch
+func (d *dispatcher) deliver(ctx context.Context, webhook models.Webhook, deliveryID string, batch []types.Log) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{ID: deliveryID, Events: len(batch), AttemptedAt: time.Now().UTC()}
+
+	events := models.NewEvents(batch)
+	for i := range events {
+		events[i].EventName, events[i].DecodedArgs, _ = d.abiRegistry.DecodeLog(events[i].Log)
+	}
+	body, err := json.Marshal(models.WebhookPayload{WebhookID: webhook.ID, DeliveryID: deliveryID, Events: events})
+	if err != nil {
+		delivery.Error = fmt.Sprintf("cannot encode the events: %v", err)
+		return delivery
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(IDHeader, webhook.ID)
+	request.Header.Set(DeliveryHeader, deliveryID)
+	request.Header.Set(TimestampHeader, timestamp)
+	if webhook.Secret != "" {
+		request.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBody)) // lets the connection be reused
+
+	delivery.StatusCode = response.StatusCode
+	delivery.Success = response.StatusCode >= 200 && response.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %s", response.Status)
+	}
+
+	return delivery
+}
+
+// Sign gets the signature of a delivery, the hex encoded hmac-sha256 of its timestamp, a dot and its body, keyed by the secret of
+// the webhook. The receivers check it against the X-Webhook-Signature header, and the timestamp against replays
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// blockBefore gets the number of the block before a block, the delivered block of the events of the block
+func blockBefore(number uint64) uint64 {
+	return max(number, 1) - 1
+}
+
+// sortWebhooks sorts the webhooks in the order of their registration
+func sortWebhooks(webhooks []models.Webhook) {
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
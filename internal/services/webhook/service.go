@@ -0,0 +1,421 @@
+/*
+Webhooks notified of the logs of their addresses, as the synchronizer indexes them.
+
+A webhook is registered through the API with a url, a list of addresses and optionally the topics of eth_getLogs and a secret. The matching logs,
+and the logs removed by a chain reorganization with removed set, are queued per webhook and posted in batches. A failed batch is retried with
+exponential backoff up to WEBHOOK_RETRY_MAX_BACKOFF, and is acknowledged only by a 2xx answer. After WEBHOOK_MAX_FAILURES consecutive failed
+attempts the webhook is disabled; its queue is kept, still receives the new logs, and is delivered once the webhook is enabled again. A dispatcher
+falling behind the feed resumes from the journal of the feed.
+
+The delivery is at-least-once within the window of the recent blocks. Each webhook stores its delivered block, the block up to which every
+matching log is acknowledged. It stays behind the checkpoint of the processed blocks and behind the logs queued or not dispatched yet, so on a
+restart the delivery resumes by reading the matching logs after it from the storage again. A full queue does not drop the logs either: they are
+read from the storage once the queue is drained. The logs acknowledged after the delivered block are delivered again then, so the receivers
+dedupe them by their block hash and log index. The registered webhooks and their delivered blocks survive restarts with the bolt backend; the
+delivery logs are in memory.
+*/
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/abiregistry"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"ethereum-tracker-app/pkg/logfilter"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxAddresses   = 100
+	maxTopics      = 4
+	maxSecretSize  = 256
+	webhookIDBytes = 16
+)
+
+type Service interface {
+	Register(ctx context.Context, request models.WebhookRequest) (models.Webhook, error)
+	GetWebhooks(ctx context.Context) []models.Webhook
+	GetWebhook(ctx context.Context, id string) (models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) (models.Webhook, error)
+	EnableWebhook(ctx context.Context, id string) (models.Webhook, error)
+	GetDeliveries(ctx context.Context, id string) ([]models.WebhookDelivery, error)
+	Run(ctx context.Context)
+}
+
+type storageService interface {
+	SetWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	GetLogsByAddressInRange(ctx context.Context, addressHex string, role models.AddressRole, fromBlock, toBlock uint64) ([]types.Log, error)
+	GetCheckpoint(ctx context.Context) (uint64, error)
+}
+
+type dispatcher struct {
+	config      config.Config
+	logger      *log.Logger
+	db          storageService
+	feed        chainfeed.Service
+	abiRegistry abiregistry.Service
+	client      *http.Client
+
+	ctx    context.Context // ends the delivery workers
+	cancel context.CancelFunc
+
+	dispatched atomic.Uint64 // sequence of the last log of the feed dispatched to the queues
+
+	mu    sync.RWMutex
+	hooks map[string]*hook
+}
+
+// NewService creates the webhooks, with the delivery workers of the stored ones. The logs are dispatched to them once Run is called
+func NewService(config config.Config, logger *log.Logger, db storageService, feed chainfeed.Service, abiRegistry abiregistry.Service) (Service, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &dispatcher{
+		config:      config,
+		logger:      logger,
+		db:          db,
+		feed:        feed,
+		abiRegistry: abiRegistry,
+		client:      newClient(config.WebhookConf.Timeout, config.WebhookConf.AllowPrivateTargets),
+		ctx:         ctx,
+		cancel:      cancel,
+		hooks:       make(map[string]*hook),
+	}
+
+	// the logs published from now on are dispatched from the feed, the ones published before are read from the storage
+	d.dispatched.Store(feed.Sequence())
+	webhooks, err := db.GetWebhooks(ctx)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "cannot load the webhooks")
+	}
+	for _, webhook := range webhooks {
+		d.start(webhook, true)
+	}
+
+	return d, nil
+}
+
+// Register validates and stores a new webhook, which is notified of the logs indexed from now on
+func (d *dispatcher) Register(ctx context.Context, request models.WebhookRequest) (models.Webhook, error) {
+	webhook, err := newWebhook(request, d.config.WebhookConf.AllowPrivateTargets)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	// the logs of the processed blocks are not delivered, the same as the logs published before
+	webhook.DeliveredBlock, _ = d.db.GetCheckpoint(ctx)
+
+	if err := d.db.SetWebhook(ctx, webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	d.start(webhook, false)
+
+	return webhook, nil
+}
+
+// GetWebhooks gets the registered webhooks, in the order of their registration
+func (d *dispatcher) GetWebhooks(ctx context.Context) []models.Webhook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	webhooks := make([]models.Webhook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		webhooks = append(webhooks, h.state())
+	}
+	sortWebhooks(webhooks)
+
+	return webhooks
+}
+
+func (d *dispatcher) GetWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	h, err := d.hook(id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	return h.state(), nil
+}
+
+// DeleteWebhook deletes a webhook and drops its queue, stopping the delivery in progress
+func (d *dispatcher) DeleteWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	h, err := d.hook(id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	h.storeMu.Lock()
+	err = d.db.DeleteWebhook(ctx, id)
+	h.deleted = err == nil
+	h.storeMu.Unlock()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	d.mu.Lock()
+	delete(d.hooks, id)
+	d.mu.Unlock()
+	h.stop()
+
+	return h.state(), nil
+}
+
+// EnableWebhook enables a webhook disabled after its failures, which resumes the delivery of its queue, including the logs queued
+// while it was disabled
+func (d *dispatcher) EnableWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	h, err := d.hook(id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	h.enable()
+	return d.store(ctx, h)
+}
+
+// GetDeliveries gets the latest delivery attempts of a webhook, the newest first
+func (d *dispatcher) GetDeliveries(ctx context.Context, id string) ([]models.WebhookDelivery, error) {
+	h, err := d.hook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.deliveryLog(), nil
+}
+
+// Run dispatches the logs published by the synchronizer to the queues of the matching webhooks, until the context is done
+func (d *dispatcher) Run(ctx context.Context) {
+	defer d.cancel()
+
+	// subscribed before reading the journal, so the logs published since the webhooks were loaded are not missed
+	sub := d.feed.SubscribeLogs()
+	defer func() { sub.Unsubscribe() }()
+	lastSequence := d.catchUp(d.dispatched.Load())
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.logger.Println("Context cancelled, stopping webhook dispatcher")
+			return
+		case txLog, ok := <-sub.C():
+			if !ok {
+				// the matching is quick, so this happens only on a burst larger than the buffer of the subscription. Subscribed again
+				// before reading the journal, so the logs published meanwhile are not missed
+				sub = d.feed.SubscribeLogs()
+				lastSequence = d.catchUp(lastSequence)
+				continue
+			}
+			if txLog.Sequence <= lastSequence {
+				continue // dispatched from the journal already
+			}
+
+			lastSequence = txLog.Sequence
+			d.dispatch(txLog.Log)
+			d.dispatched.Store(lastSequence)
+		}
+	}
+}
+
+// catchUp dispatches the logs published after a sequence from the journal of the feed, and gets the sequence of the last one
+func (d *dispatcher) catchUp(lastSequence uint64) uint64 {
+	missed := d.feed.LogsAfter(lastSequence)
+	if len(missed) != 0 && missed[0].Sequence != lastSequence+1 {
+		d.logger.Println("webhook dispatcher fell behind the journal of the indexed logs, some logs are not delivered")
+	}
+
+	for _, txLog := range missed {
+		lastSequence = txLog.Sequence
+		d.dispatch(txLog.Log)
+		d.dispatched.Store(lastSequence)
+	}
+
+	return lastSequence
+}
+
+// dispatch queues a log to the webhooks matching it
+func (d *dispatcher) dispatch(txLog types.Log) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, h := range d.hooks {
+		if logfilter.Match(h.query, txLog) {
+			h.enqueue(txLog)
+		}
+	}
+}
+
+// start adds a webhook with its delivery worker. A stored webhook resumes by reading the logs after its delivered block from the storage
+func (d *dispatcher) start(webhook models.Webhook, stored bool) {
+	h := newHook(d.ctx, webhook, d.config.WebhookConf.RetryBaseBackoff, d.config.WebhookConf.RetryMaxBackoff)
+	if stored {
+		h.overflowAt(webhook.DeliveredBlock + 1)
+	}
+
+	d.mu.Lock()
+	d.hooks[webhook.ID] = h
+	d.mu.Unlock()
+
+	go d.deliverLoop(h)
+}
+
+// store writes the state of a webhook. The writes are serialized, so an older state does not overwrite a newer one, and a deleted
+// webhook is not written again
+func (d *dispatcher) store(ctx context.Context, h *hook) (models.Webhook, error) {
+	h.storeMu.Lock()
+	defer h.storeMu.Unlock()
+
+	webhook := h.state()
+	if h.deleted {
+		return webhook, nil
+	}
+	if err := d.db.SetWebhook(ctx, webhook); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// readStored reads the stored logs of a webhook from a block on, in the order of the chain. The logs removed by a chain
+// reorganization come before the logs replacing them
+func (d *dispatcher) readStored(ctx context.Context, h *hook, fromBlock uint64) ([]types.Log, error) {
+	logs := make([]types.Log, 0)
+	for _, address := range h.query.Addresses {
+		stored, err := d.db.GetLogsByAddressInRange(ctx, address.Hex(), models.AddressRoleEmitter, fromBlock, math.MaxUint64)
+		if err != nil {
+			return nil, err
+		}
+		for _, txLog := range stored {
+			if logfilter.Match(h.query, txLog) {
+				logs = append(logs, txLog)
+			}
+		}
+	}
+
+	slices.SortStableFunc(logs, func(a, b types.Log) int {
+		switch {
+		case a.BlockNumber != b.BlockNumber:
+			return cmp.Compare(a.BlockNumber, b.BlockNumber)
+		case a.Removed != b.Removed:
+			if a.Removed {
+				return -1
+			}
+			return 1
+		default:
+			return cmp.Compare(a.Index, b.Index)
+		}
+	})
+
+	return logs, nil
+}
+
+// saveCursor stores the delivered block of a webhook, if it changed. It is the block before the oldest log of the webhook which may
+// not be acknowledged: the logs of the blocks after the checkpoint may not be published yet, the logs after the dispatched sequence
+// are not queued yet, and the queued logs and the logs to be read from the storage are not acknowledged yet
+func (d *dispatcher) saveCursor(h *hook) {
+	checkpoint, err := d.db.GetCheckpoint(h.ctx)
+	if err != nil {
+		return // no block is processed yet
+	}
+
+	cursor := checkpoint
+	for _, txLog := range d.feed.LogsAfter(d.dispatched.Load()) {
+		if logfilter.Match(h.query, txLog.Log) {
+			cursor = min(cursor, blockBefore(txLog.BlockNumber))
+		}
+	}
+	if !h.advance(cursor) {
+		return
+	}
+
+	if _, err := d.store(h.ctx, h); err != nil {
+		d.logger.Printf("failed to store the delivered block of webhook %s: %v", h.state().ID, err)
+	}
+}
+
+func (d *dispatcher) hook(id string) (*hook, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	h, ok := d.hooks[id]
+	if !ok {
+		return nil, customerror.NewNotFoundError("webhook does not exist", fmt.Errorf("webhook %s is not registered", id))
+	}
+
+	return h, nil
+}
+
+// newWebhook validates the registration of a webhook and creates it, enabled. The url must not target the host of the service or
+// its network, unless the private targets are allowed
+func newWebhook(request models.WebhookRequest, allowPrivateTargets bool) (models.Webhook, error) {
+	endpoint, err := url.Parse(request.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return models.Webhook{}, customerror.NewInvalidInputError("Invalid input: url must be an absolute http or https url", err)
+	}
+	if !allowPrivateTargets {
+		if err := validateTarget(endpoint); err != nil {
+			return models.Webhook{}, customerror.NewInvalidInputError("Invalid input: url must not target a loopback, private or link-local host", err)
+		}
+	}
+
+	if len(request.Addresses) == 0 || len(request.Addresses) > maxAddresses {
+		return models.Webhook{}, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: addresses must have 1 to %d addresses", maxAddresses), nil)
+	}
+	addresses := make([]common.Address, len(request.Addresses))
+	for i, address := range request.Addresses {
+		if !common.IsHexAddress(address) {
+			return models.Webhook{}, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: address %s is not a valid hex address", address), nil)
+		}
+		addresses[i] = common.HexToAddress(address)
+	}
+
+	if len(request.Topics) > maxTopics {
+		return models.Webhook{}, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: topics must have at most %d positions", maxTopics), nil)
+	}
+	topics := make([][]common.Hash, len(request.Topics))
+	for position, sub := range request.Topics {
+		topics[position] = make([]common.Hash, len(sub))
+		for i, value := range sub {
+			topic, err := hexutil.Decode(value)
+			if err != nil || len(topic) != common.HashLength {
+				return models.Webhook{}, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: topic%d value %s is not a valid 32 bytes hex", position, value), err)
+			}
+			topics[position][i] = common.BytesToHash(topic)
+		}
+	}
+
+	if len(request.Secret) > maxSecretSize {
+		return models.Webhook{}, customerror.NewInvalidInputError(fmt.Sprintf("Invalid input: secret must have at most %d characters", maxSecretSize), nil)
+	}
+
+	id := make([]byte, webhookIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return models.Webhook{}, errors.Wrap(err, "cannot generate the id of the webhook")
+	}
+
+	return models.Webhook{
+		ID:        hex.EncodeToString(id),
+		URL:       endpoint.String(),
+		Addresses: addresses,
+		Topics:    topics,
+		Secret:    request.Secret,
+		Signed:    request.Secret != "",
+		Enabled:   true,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
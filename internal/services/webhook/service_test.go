@@ -0,0 +1,404 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/services/abiregistry"
+	"ethereum-tracker-app/internal/services/chainfeed"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	token         = common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	transferTopic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+)
+
+// receiver is a webhook endpoint answering with the given status, recording the requests
+type receiver struct {
+	status   atomic.Int32
+	mu       sync.Mutex
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+	rc.mu.Unlock()
+
+	w.WriteHeader(int(rc.status.Load()))
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return append([]receivedRequest(nil), rc.requests...)
+}
+
+var testConfig = config.Config{
+	StreamConf: config.StreamConf{SubscriptionBufferSize: 16},
+	WebhookConf: config.WebhookConf{
+		Timeout: time.Second, RetryBaseBackoff: time.Millisecond, RetryMaxBackoff: 5 * time.Millisecond, MaxFailures: 3,
+		AllowPrivateTargets: true, // the receivers of the tests listen on the loopback
+	},
+}
+
+func newTestService(t *testing.T) (Service, inmemorydb.Service) {
+	db := inmemorydb.NewInmemortDBService(testConfig, log.New(os.Stdout, "app", log.LstdFlags))
+	return newTestServiceWithDB(t, db), db
+}
+
+// newTestServiceWithDB creates the webhooks of a storage with a new feed, as they are on a restart
+func newTestServiceWithDB(t *testing.T, db inmemorydb.Service) Service {
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	registry, err := abiregistry.NewRegistry(testConfig, logger)
+	require.NoError(t, err)
+
+	srv, err := NewService(testConfig, logger, db, chainfeed.NewService(testConfig, logger), registry)
+	require.NoError(t, err)
+	t.Cleanup(srv.(*dispatcher).cancel)
+
+	return srv
+}
+
+// deliveredLogs gets the logs acknowledged by a receiver by their block number and log index, deduplicated
+func deliveredLogs(t *testing.T, requests []receivedRequest) map[[2]uint64]bool {
+	delivered := make(map[[2]uint64]bool)
+	for _, request := range requests {
+		var payload struct {
+			Events []struct {
+				BlockNumber hexutil.Uint64 `json:"blockNumber"`
+				Index       hexutil.Uint   `json:"logIndex"`
+			} `json:"events"`
+		}
+		require.NoError(t, json.Unmarshal(request.body, &payload))
+		for _, event := range payload.Events {
+			delivered[[2]uint64{uint64(event.BlockNumber), uint64(event.Index)}] = true
+		}
+	}
+
+	return delivered
+}
+
+func TestDeliveryRetried(t *testing.T) {
+	srv, _ := newTestService(t)
+	ctx := context.Background()
+
+	rc := &receiver{}
+	rc.status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	webhook, err := srv.Register(ctx, models.WebhookRequest{
+		URL:       server.URL,
+		Addresses: []string{token.Hex()},
+		Topics:    [][]string{{transferTopic.Hex()}},
+		Secret:    "s3cret",
+	})
+	require.NoError(t, err)
+	assert.True(t, webhook.Enabled)
+	assert.True(t, webhook.Signed)
+
+	srv.(*dispatcher).dispatch(types.Log{Address: common.Address{}, Topics: []common.Hash{transferTopic}, BlockNumber: 1})
+	srv.(*dispatcher).dispatch(types.Log{Address: token, Topics: []common.Hash{{}}, BlockNumber: 1, Index: 1})
+	srv.(*dispatcher).dispatch(types.Log{Address: token, Topics: []common.Hash{transferTopic}, BlockNumber: 1, Index: 2})
+
+	// the first attempt fails, the retry of the same delivery succeeds
+	require.Eventually(t, func() bool { return len(rc.received()) >= 1 }, time.Second, time.Millisecond)
+	rc.status.Store(http.StatusNoContent)
+	require.Eventually(t, func() bool {
+		deliveries, err := srv.GetDeliveries(ctx, webhook.ID)
+		return err == nil && len(deliveries) != 0 && deliveries[0].Success
+	}, time.Second, time.Millisecond)
+
+	requests := rc.received()
+	first, last := requests[0], requests[len(requests)-1]
+	assert.Equal(t, webhook.ID, last.header.Get(IDHeader))
+	assert.Equal(t, first.header.Get(DeliveryHeader), last.header.Get(DeliveryHeader), "a retry has the id of the delivery")
+	timestamp := last.header.Get(TimestampHeader)
+	assert.Equal(t, "sha256="+Sign("s3cret", timestamp, last.body), last.header.Get(SignatureHeader))
+
+	var payload struct {
+		DeliveryID string                   `json:"deliveryId"`
+		Events     []map[string]interface{} `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(last.body, &payload))
+	assert.Equal(t, last.header.Get(DeliveryHeader), payload.DeliveryID)
+	require.Len(t, payload.Events, 1, "only the log matching the address and the topics is delivered")
+	assert.Equal(t, "0x2", payload.Events[0]["logIndex"])
+
+	deliveries, err := srv.GetDeliveries(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, len(requests), deliveries[0].Attempt)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+	assert.False(t, deliveries[len(deliveries)-1].Success)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[len(deliveries)-1].StatusCode)
+}
+
+func TestWebhookDisabled(t *testing.T) {
+	srv, db := newTestService(t)
+	ctx := context.Background()
+
+	rc := &receiver{}
+	rc.status.Store(http.StatusInternalServerError)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	webhook, err := srv.Register(ctx, models.WebhookRequest{URL: server.URL, Addresses: []string{token.Hex()}})
+	require.NoError(t, err)
+	srv.(*dispatcher).dispatch(types.Log{Address: token, BlockNumber: 1})
+
+	require.Eventually(t, func() bool {
+		stored, err := db.GetWebhooks(ctx)
+		return err == nil && len(stored) == 1 && !stored[0].Enabled
+	}, time.Second, time.Millisecond, "the disabled webhook is stored")
+	disabled, err := srv.GetWebhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.False(t, disabled.Enabled)
+	assert.Equal(t, 3, disabled.ConsecutiveFailures)
+	assert.Contains(t, disabled.DisabledReason, "500")
+	assert.Len(t, rc.received(), 3)
+	assert.Empty(t, rc.received()[0].header.Get(SignatureHeader), "a webhook without a secret is not signed")
+
+	// the logs of a disabled webhook are queued too, its queue is delivered once enabled
+	srv.(*dispatcher).dispatch(types.Log{Address: token, BlockNumber: 2})
+	rc.status.Store(http.StatusOK)
+	enabled, err := srv.EnableWebhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.True(t, enabled.Enabled)
+	assert.Zero(t, enabled.ConsecutiveFailures)
+	require.Eventually(t, func() bool {
+		deliveries, err := srv.GetDeliveries(ctx, webhook.ID)
+		return err == nil && len(deliveries) == 5 && deliveries[0].Success && deliveries[1].Success
+	}, time.Second, time.Millisecond, "the failed batch is delivered, then the log queued while disabled")
+	var payload struct {
+		Events []map[string]interface{} `json:"events"`
+	}
+	requests := rc.received()
+	require.NoError(t, json.Unmarshal(requests[len(requests)-1].body, &payload))
+	require.Len(t, payload.Events, 1)
+	assert.Equal(t, "0x2", payload.Events[0]["blockNumber"])
+
+	deleted, err := srv.DeleteWebhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.ID, deleted.ID)
+	stored, err := db.GetWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+	_, err = srv.GetWebhook(ctx, webhook.ID)
+	var customErr *customerror.Error
+	require.True(t, errors.As(err, &customErr))
+	assert.Equal(t, customerror.ErrCodeNotFound, customErr.Code)
+}
+
+func TestCatchUp(t *testing.T) {
+	srv, _ := newTestService(t)
+	d := srv.(*dispatcher)
+	ctx := context.Background()
+
+	rc := &receiver{}
+	rc.status.Store(http.StatusOK)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	_, err := srv.Register(ctx, models.WebhookRequest{URL: server.URL, Addresses: []string{token.Hex()}})
+	require.NoError(t, err)
+
+	// the logs published while the dispatcher was dropped by the feed are dispatched from the journal
+	lastSequence := d.feed.Sequence()
+	d.feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 1}, {Address: token, BlockNumber: 1, Index: 1}}, true)
+	d.feed.PublishLogs([]types.Log{{Address: token, BlockNumber: 1, Index: 1, Removed: true}}, true)
+
+	assert.Equal(t, lastSequence+3, d.catchUp(lastSequence))
+	require.Eventually(t, func() bool {
+		delivered := 0
+		for _, request := range rc.received() {
+			var payload struct {
+				Events []json.RawMessage `json:"events"`
+			}
+			require.NoError(t, json.Unmarshal(request.body, &payload))
+			delivered += len(payload.Events)
+		}
+		return delivered == 3
+	}, time.Second, time.Millisecond)
+}
+
+func TestDeliveryResumedAfterRestart(t *testing.T) {
+	srv, db := newTestService(t)
+	d := srv.(*dispatcher)
+	ctx, cancel := context.WithCancel(context.Background())
+	go srv.Run(ctx)
+
+	rc := &receiver{}
+	rc.status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	webhook, err := srv.Register(context.Background(), models.WebhookRequest{URL: server.URL, Addresses: []string{token.Hex()}})
+	require.NoError(t, err)
+
+	// the logs of blocks 5 and 6 are stored and published, but not acknowledged before the restart
+	logs := []types.Log{{Address: token, BlockNumber: 5}, {Address: token, BlockNumber: 6, Index: 1}}
+	for i := range logs {
+		require.NoError(t, db.SetLogByAddress(ctx, token.Hex(), &logs[i]))
+	}
+	require.NoError(t, db.SetCheckpoint(ctx, 6))
+	d.feed.PublishLogs(logs, true)
+	require.Eventually(t, func() bool { return len(rc.received()) >= 1 }, time.Second, time.Millisecond)
+	cancel()
+
+	rc.status.Store(http.StatusOK)
+	restarted := newTestServiceWithDB(t, db)
+	go restarted.Run(context.Background())
+	_, err = restarted.EnableWebhook(context.Background(), webhook.ID) // if disabled by the failures before the restart
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		stored, err := db.GetWebhooks(context.Background())
+		return err == nil && len(stored) == 1 && stored[0].DeliveredBlock == 6
+	}, time.Second, time.Millisecond, "the delivered block follows the acknowledged logs")
+	delivered := deliveredLogs(t, rc.received())
+	assert.True(t, delivered[[2]uint64{5, 0}], "the log queued before the restart is delivered")
+	assert.True(t, delivered[[2]uint64{6, 1}], "the log queued before the restart is delivered")
+
+	resumed, err := restarted.GetWebhook(context.Background(), webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), resumed.DeliveredBlock)
+}
+
+func TestQueueOverflow(t *testing.T) {
+	srv, db := newTestService(t)
+	d := srv.(*dispatcher)
+	ctx := context.Background()
+
+	rc := &receiver{}
+	rc.status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	webhook, err := srv.Register(ctx, models.WebhookRequest{URL: server.URL, Addresses: []string{token.Hex()}})
+	require.NoError(t, err)
+
+	// more logs than the queue holds, the last ones of a backfilled block older than the queued ones
+	total := maxPendingEvents + 150
+	for i := 0; i < total; i++ {
+		txLog := types.Log{Address: token, BlockNumber: uint64(100 + i/100), Index: uint(i % 100)}
+		if i >= maxPendingEvents+100 {
+			txLog.BlockNumber, txLog.Index = 50, uint(i-maxPendingEvents-100)
+		}
+		require.NoError(t, db.SetLogByAddress(ctx, token.Hex(), &txLog))
+		d.dispatch(txLog)
+	}
+
+	h, err := d.hook(webhook.ID)
+	require.NoError(t, err)
+	h.mu.Lock()
+	assert.Len(t, h.pending, maxPendingEvents, "the queue is full")
+	assert.True(t, h.overflow)
+	assert.Equal(t, uint64(50), h.overflowFrom, "the events not queued are read from their oldest block")
+	h.mu.Unlock()
+	require.NoError(t, db.SetCheckpoint(ctx, uint64(100+total/100)))
+	h.advance(math.MaxUint64)
+	assert.Equal(t, uint64(49), h.state().DeliveredBlock, "the delivered block stays before the events not queued")
+
+	rc.status.Store(http.StatusOK)
+	_, err = srv.EnableWebhook(ctx, webhook.ID) // if disabled by the failures meanwhile
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.pending) == 0 && !h.overflow
+	}, 10*time.Second, time.Millisecond, "the events not queued are delivered from the storage")
+	assert.Len(t, deliveredLogs(t, rc.received()), total, "no event is dropped")
+}
+
+func TestRegisterValidation(t *testing.T) {
+	srv, _ := newTestService(t)
+	ctx := context.Background()
+
+	type testCase struct {
+		name    string
+		request models.WebhookRequest
+	}
+	for _, tt := range []testCase{
+		{name: "relative url", request: models.WebhookRequest{URL: "/hook", Addresses: []string{token.Hex()}}},
+		{name: "not http", request: models.WebhookRequest{URL: "ftp://example.com", Addresses: []string{token.Hex()}}},
+		{name: "no addresses", request: models.WebhookRequest{URL: "https://example.com"}},
+		{name: "invalid address", request: models.WebhookRequest{URL: "https://example.com", Addresses: []string{"0x12"}}},
+		{name: "too many topics", request: models.WebhookRequest{URL: "https://example.com", Addresses: []string{token.Hex()}, Topics: make([][]string, 5)}},
+		{name: "invalid topic", request: models.WebhookRequest{URL: "https://example.com", Addresses: []string{token.Hex()}, Topics: [][]string{{"0x12"}}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srv.Register(ctx, tt.request)
+			var customErr *customerror.Error
+			require.True(t, errors.As(err, &customErr))
+			assert.Equal(t, customerror.ErrCodeInvalidInput, customErr.Code)
+		})
+	}
+	assert.Empty(t, srv.GetWebhooks(ctx))
+}
+
+func TestPrivateTargets(t *testing.T) {
+	for _, target := range []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		_, err := newWebhook(models.WebhookRequest{URL: target, Addresses: []string{token.Hex()}}, false)
+		var customErr *customerror.Error
+		require.True(t, errors.As(err, &customErr), target)
+		assert.Equal(t, customerror.ErrCodeInvalidInput, customErr.Code, target)
+
+		_, err = newWebhook(models.WebhookRequest{URL: target, Addresses: []string{token.Hex()}}, true)
+		assert.NoError(t, err, "%s is allowed with the private targets", target)
+	}
+
+	_, err := newWebhook(models.WebhookRequest{URL: "https://8.8.8.8/hook", Addresses: []string{token.Hex()}}, false)
+	assert.NoError(t, err)
+	_, err = newWebhook(models.WebhookRequest{URL: "https://hooks.example.com/hook", Addresses: []string{token.Hex()}}, false)
+	assert.NoError(t, err, "the names are checked once resolved")
+
+	// the address of a name, or of a redirect, is checked when the webhook is posted to
+	rc := &receiver{}
+	rc.status.Store(http.StatusOK)
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	_, err = newClient(time.Second, false).Post(server.URL, "application/json", nil)
+	assert.ErrorContains(t, err, "loopback, private or link-local")
+	response, err := newClient(time.Second, true).Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Len(t, rc.received(), 1)
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade nat range of RFC 6598, internal to the providers like the RFC 1918 ranges
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPrivateAddress reports whether an ip address is internal to the host or its network: the loopback, the private ranges, the
+// link-local ranges with the cloud metadata endpoint 169.254.169.254, the unspecified and the multicast addresses
+func isPrivateAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// validateTarget rejects the url of a webhook naming a host internal to the service, by its name or by its ip address. The names
+// resolving to such an address are rejected when the webhook is posted to, see newClient
+func validateTarget(endpoint *url.URL) error {
+	host := strings.TrimSuffix(strings.ToLower(endpoint.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("host %s is a loopback host", host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && isPrivateAddress(ip) {
+		return fmt.Errorf("address %s is a loopback, private or link-local address", ip)
+	}
+
+	return nil
+}
+
+// newClient creates the http client posting to the webhooks. Unless the private targets are allowed, the client refuses to connect to
+// a private address, checked once the host is resolved so a name resolving to one, or a redirect to one, is refused too. The proxies
+// of the environment are not used, as the client would check the address of the proxy instead of the webhook
+func newClient(timeout time.Duration, allowPrivateTargets bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if isPrivateAddress(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is a loopback, private or link-local address", addrPort.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,497 @@
+/*
+Persistent embedded storage backend, selected by STORAGE_BACKEND=bolt.
+
+The ingested data of the window of the recent blocks is written to a bbolt file keyed by the block number, and the registered webhooks by their id.
+The reads are served by the in-memory database, whose indexes are rebuilt from the file on startup.
+*/
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/internal/storage/inmemorydb"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/customerror"
+	"ethereum-tracker-app/pkg/tokentransfer"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	blocksBucket      = []byte("blocks")      // block number -> rlp encoded block
+	txLogsBucket      = []byte("txLogs")      // block number + transaction hash -> json encoded logs of the transaction
+	removedLogsBucket = []byte("removedLogs") // block number + block hash -> json encoded logs removed by a chain reorganization
+	receiptsBucket    = []byte("receipts")    // block number + transaction hash -> json encoded receipt without its logs
+	addressTxsBucket  = []byte("addressTxs")  // block number + transaction hash -> json encoded transaction of the addresses
+	webhooksBucket    = []byte("webhooks")    // webhook id -> json encoded webhook with its secret
+	metaBucket        = []byte("meta")        // metadata of the ingestion, like the checkpoint, the block tags and the dead letters
+
+	checkpointKey  = []byte("checkpoint")
+	deadLettersKey = []byte("deadLetters")
+	tagKeyPrefix   = []byte("tag:")
+)
+
+// storedWebhook is a webhook on disk, with the secret which is not encoded otherwise
+type storedWebhook struct {
+	models.Webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+type boltDB struct {
+	inmemorydb.Service // serves the reads
+
+	config config.Config
+	logger *log.Logger
+	db     *bolt.DB
+}
+
+func NewBoltDBService(config config.Config, logger *log.Logger) (inmemorydb.Service, error) {
+	db, err := bolt.Open(config.StorageConf.Path, 0600, nil)
+	if err != nil {
+		return nil, customerror.NewStorageError("", errors.Wrapf(err, "cannot open the database file %s", config.StorageConf.Path))
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{blocksBucket, txLogsBucket, removedLogsBucket, receiptsBucket, addressTxsBucket, webhooksBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, customerror.NewStorageError("", errors.Wrap(err, "cannot create the buckets of the database"))
+	}
+
+	boltDB := &boltDB{
+		Service: inmemorydb.NewInmemortDBService(config, logger),
+		config:  config,
+		logger:  logger,
+		db:      db,
+	}
+
+	if err := boltDB.load(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return boltDB, nil
+}
+
+// SetBlock stores the block on disk and evicts the blocks which fall out of the window
+func (b *boltDB) SetBlock(ctx context.Context, block *types.Block) error {
+	encodedBlock, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot encode block %d", block.NumberU64()))
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if b.isOutOfWindow(tx, block.NumberU64()) {
+			return nil
+		}
+		if err := tx.Bucket(blocksBucket).Put(blockKey(block.NumberU64()), encodedBlock); err != nil {
+			return err
+		}
+		return b.evictOldBlocks(tx)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store block %d", block.NumberU64()))
+	}
+
+	return b.Service.SetBlock(ctx, block)
+}
+
+// SetLogsByTx stores the logs of a transaction on disk
+func (b *boltDB) SetLogsByTx(ctx context.Context, txHashHex string, logs []*types.Log) error {
+	if len(logs) == 0 {
+		return b.Service.SetLogsByTx(ctx, txHashHex, logs)
+	}
+
+	encodedLogs, err := encodeLogs(logs)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot encode logs of transaction %s", txHashHex))
+	}
+
+	// batched, as the logs of transactions are stored concurrently by the workers
+	key := append(blockKey(logs[0].BlockNumber), common.HexToHash(txHashHex).Bytes()...)
+	err = b.db.Batch(func(tx *bolt.Tx) error {
+		if b.isOutOfWindow(tx, logs[0].BlockNumber) {
+			return nil
+		}
+		return tx.Bucket(txLogsBucket).Put(key, encodedLogs)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store logs of transaction %s", txHashHex))
+	}
+
+	return b.Service.SetLogsByTx(ctx, txHashHex, logs)
+}
+
+// SetReceipt stores the receipt of a transaction on disk, without its logs which are stored by SetLogsByTx
+func (b *boltDB) SetReceipt(ctx context.Context, receipt *types.Receipt) error {
+	withoutLogs := *receipt
+	withoutLogs.Logs = []*types.Log{} // logs are required when decoding
+	encodedReceipt, err := json.Marshal(&withoutLogs)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot encode receipt of transaction %s", receipt.TxHash.Hex()))
+	}
+
+	key := append(blockKey(receipt.BlockNumber.Uint64()), receipt.TxHash.Bytes()...)
+	err = b.db.Batch(func(tx *bolt.Tx) error {
+		if b.isOutOfWindow(tx, receipt.BlockNumber.Uint64()) {
+			return nil
+		}
+		return tx.Bucket(receiptsBucket).Put(key, encodedReceipt)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store receipt of transaction %s", receipt.TxHash.Hex()))
+	}
+
+	return b.Service.SetReceipt(ctx, receipt)
+}
+
+// SetAddressTransaction stores a transaction of its sender and recipient on disk
+func (b *boltDB) SetAddressTransaction(ctx context.Context, transaction *models.AddressTransaction) error {
+	encodedTransaction, err := json.Marshal(transaction)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot encode transaction %s", transaction.Hash))
+	}
+
+	key := append(blockKey(transaction.BlockNumber), common.HexToHash(transaction.Hash).Bytes()...)
+	err = b.db.Batch(func(tx *bolt.Tx) error {
+		if b.isOutOfWindow(tx, transaction.BlockNumber) {
+			return nil
+		}
+		return tx.Bucket(addressTxsBucket).Put(key, encodedTransaction)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store transaction %s", transaction.Hash))
+	}
+
+	return b.Service.SetAddressTransaction(ctx, transaction)
+}
+
+// RollbackBlock removes an orphaned block from disk and keeps its logs flagged as removed
+func (b *boltDB) RollbackBlock(ctx context.Context, number uint64) ([]types.Log, error) {
+	block, err := b.Service.GetBlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	removedLogs, err := b.Service.RollbackBlock(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]*types.Log, len(removedLogs))
+	for i := range removedLogs {
+		logs[i] = &removedLogs[i]
+	}
+	encodedLogs, err := encodeLogs(logs)
+	if err != nil {
+		return nil, customerror.NewStorageError("", errors.Wrapf(err, "cannot encode removed logs of block %d", number))
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(blocksBucket).Delete(blockKey(number)); err != nil {
+			return err
+		}
+		if err := deletePrefix(tx.Bucket(txLogsBucket), blockKey(number)); err != nil {
+			return err
+		}
+		if err := deletePrefix(tx.Bucket(receiptsBucket), blockKey(number)); err != nil {
+			return err
+		}
+		if err := deletePrefix(tx.Bucket(addressTxsBucket), blockKey(number)); err != nil {
+			return err
+		}
+		if len(removedLogs) == 0 {
+			return nil
+		}
+		return tx.Bucket(removedLogsBucket).Put(append(blockKey(number), block.Hash().Bytes()...), encodedLogs)
+	})
+	if err != nil {
+		return nil, customerror.NewStorageError("", errors.Wrapf(err, "cannot rollback block %d", number))
+	}
+
+	return removedLogs, nil
+}
+
+// SetCheckpoint stores the checkpoint on disk
+func (b *boltDB) SetCheckpoint(ctx context.Context, number uint64) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(checkpointKey, blockKey(number))
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store the checkpoint %d", number))
+	}
+
+	return b.Service.SetCheckpoint(ctx, number)
+}
+
+// SetBlockTag stores the block number of a tag on disk
+func (b *boltDB) SetBlockTag(ctx context.Context, tag models.BlockTag, number uint64) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(tagKey(tag), blockKey(number))
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store the %s block %d", tag, number))
+	}
+
+	return b.Service.SetBlockTag(ctx, tag, number)
+}
+
+// SetDeadLetters stores the dead letters of the retries on disk
+func (b *boltDB) SetDeadLetters(ctx context.Context, deadLetters []models.DeadLetter) error {
+	encodedDeadLetters, err := json.Marshal(deadLetters)
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrap(err, "cannot encode the dead letters"))
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(deadLettersKey, encodedDeadLetters)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrap(err, "cannot store the dead letters"))
+	}
+
+	return b.Service.SetDeadLetters(ctx, deadLetters)
+}
+
+// SetWebhook stores a webhook on disk
+func (b *boltDB) SetWebhook(ctx context.Context, webhook models.Webhook) error {
+	encodedWebhook, err := json.Marshal(storedWebhook{Webhook: webhook, Secret: webhook.Secret})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot encode webhook %s", webhook.ID))
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).Put([]byte(webhook.ID), encodedWebhook)
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot store webhook %s", webhook.ID))
+	}
+
+	return b.Service.SetWebhook(ctx, webhook)
+}
+
+// DeleteWebhook deletes a webhook from disk
+func (b *boltDB) DeleteWebhook(ctx context.Context, id string) error {
+	if err := b.Service.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrapf(err, "cannot delete webhook %s", id))
+	}
+
+	return nil
+}
+
+// Close closes the database file
+func (b *boltDB) Close() error {
+	return b.db.Close()
+}
+
+// load rebuilds the in-memory indexes from the disk, block by block from the oldest one
+func (b *boltDB) load(ctx context.Context) error {
+	err := b.db.View(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(blocksBucket).Cursor()
+		for key, value := blocks.First(); key != nil; key, value = blocks.Next() {
+			block := new(types.Block)
+			if err := rlp.DecodeBytes(value, block); err != nil {
+				return errors.Wrapf(err, "cannot decode block %d", binary.BigEndian.Uint64(key))
+			}
+			if err := b.Service.SetBlock(ctx, block); err != nil {
+				return err
+			}
+
+			// the transaction hashes are derived from the stored block, instead of being stored twice
+			txHashes := make([]string, len(block.Transactions()))
+			for i, tx := range block.Transactions() {
+				txHashes[i] = tx.Hash().Hex()
+			}
+			if err := b.Service.SetTransactionHashes(ctx, block.NumberU64(), txHashes); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range [][]byte{removedLogsBucket, txLogsBucket} {
+			err := tx.Bucket(name).ForEach(func(key, value []byte) error {
+				var logs []*types.Log
+				if err := json.Unmarshal(value, &logs); err != nil {
+					return errors.Wrapf(err, "cannot decode logs of block %d", binary.BigEndian.Uint64(key[:8]))
+				}
+				if len(logs) == 0 {
+					return nil
+				}
+
+				if bytes.Equal(name, txLogsBucket) {
+					if err := b.Service.SetLogsByTx(ctx, logs[0].TxHash.Hex(), logs); err != nil {
+						return err
+					}
+				}
+				for _, txLog := range logs {
+					if err := b.Service.SetLogByAddress(ctx, txLog.Address.Hex(), txLog); err != nil {
+						return err
+					}
+
+					// the token transfers are derived from the stored logs, instead of being stored twice
+					transfers := tokentransfer.Parse(*txLog)
+					for i := range transfers {
+						if err := b.Service.SetTransfer(ctx, &transfers[i]); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Bucket(receiptsBucket).ForEach(func(key, value []byte) error {
+			receipt := new(types.Receipt)
+			if err := json.Unmarshal(value, receipt); err != nil {
+				return errors.Wrapf(err, "cannot decode receipt of block %d", binary.BigEndian.Uint64(key[:8]))
+			}
+			return b.Service.SetReceipt(ctx, receipt)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(addressTxsBucket).ForEach(func(key, value []byte) error {
+			transaction := new(models.AddressTransaction)
+			if err := json.Unmarshal(value, transaction); err != nil {
+				return errors.Wrapf(err, "cannot decode transaction of block %d", binary.BigEndian.Uint64(key[:8]))
+			}
+			return b.Service.SetAddressTransaction(ctx, transaction)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(webhooksBucket).ForEach(func(key, value []byte) error {
+			var webhook storedWebhook
+			if err := json.Unmarshal(value, &webhook); err != nil {
+				return errors.Wrapf(err, "cannot decode webhook %s", key)
+			}
+			webhook.Webhook.Secret = webhook.Secret
+			return b.Service.SetWebhook(ctx, webhook.Webhook)
+		})
+		if err != nil {
+			return err
+		}
+
+		meta := tx.Bucket(metaBucket).Cursor()
+		for key, value := meta.Seek(tagKeyPrefix); bytes.HasPrefix(key, tagKeyPrefix); key, value = meta.Next() {
+			tag := models.BlockTag(key[len(tagKeyPrefix):])
+			if err := b.Service.SetBlockTag(ctx, tag, binary.BigEndian.Uint64(value)); err != nil {
+				return err
+			}
+		}
+
+		if encodedDeadLetters := tx.Bucket(metaBucket).Get(deadLettersKey); encodedDeadLetters != nil {
+			var deadLetters []models.DeadLetter
+			if err := json.Unmarshal(encodedDeadLetters, &deadLetters); err != nil {
+				return errors.Wrap(err, "cannot decode the dead letters")
+			}
+			if err := b.Service.SetDeadLetters(ctx, deadLetters); err != nil {
+				return err
+			}
+		}
+
+		if checkpoint := tx.Bucket(metaBucket).Get(checkpointKey); checkpoint != nil {
+			return b.Service.SetCheckpoint(ctx, binary.BigEndian.Uint64(checkpoint))
+		}
+		return nil
+	})
+	if err != nil {
+		return customerror.NewStorageError("", errors.Wrap(err, "cannot load the stored data"))
+	}
+
+	return nil
+}
+
+// isOutOfWindow reports whether a block number is already older than the window of the highest stored block
+func (b *boltDB) isOutOfWindow(tx *bolt.Tx, number uint64) bool {
+	windowSize := uint64(b.config.EthClientConf.NumberOfRecentBlocks)
+	head, _ := tx.Bucket(blocksBucket).Cursor().Last()
+
+	return windowSize != 0 && head != nil && number+windowSize <= binary.BigEndian.Uint64(head)
+}
+
+// evictOldBlocks deletes everything stored for the blocks which fall out of the window of the highest stored block
+func (b *boltDB) evictOldBlocks(tx *bolt.Tx) error {
+	windowSize := uint64(b.config.EthClientConf.NumberOfRecentBlocks)
+	head, _ := tx.Bucket(blocksBucket).Cursor().Last()
+	if windowSize == 0 || head == nil || binary.BigEndian.Uint64(head) < windowSize {
+		return nil
+	}
+
+	cutoff := blockKey(binary.BigEndian.Uint64(head) - windowSize + 1)
+	for _, name := range [][]byte{blocksBucket, txLogsBucket, removedLogsBucket, receiptsBucket, addressTxsBucket} {
+		cursor := tx.Bucket(name).Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, cutoff) < 0; key, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deletePrefix deletes all the keys of a bucket starting with a prefix
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	cursor := bucket.Cursor()
+	for key, _ := cursor.Seek(prefix); bytes.HasPrefix(key, prefix); key, _ = cursor.Seek(prefix) {
+		if err := cursor.Delete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// blockKey encodes a block number in big endian, so the keys are sorted by the block number
+func blockKey(number uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, number)
+
+	return key
+}
+
+// tagKey gets the key of a block tag in the meta bucket
+func tagKey(tag models.BlockTag) []byte {
+	key := make([]byte, 0, len(tagKeyPrefix)+len(tag))
+
+	return append(append(key, tagKeyPrefix...), tag...)
+}
+
+// encodeLogs encodes the logs in json, which unlike rlp keeps the derived fields of the logs
+func encodeLogs(logs []*types.Log) ([]byte, error) {
+	encodable := make([]types.Log, len(logs))
+	for i, txLog := range logs {
+		encodable[i] = *txLog
+		if encodable[i].Topics == nil {
+			encodable[i].Topics = []common.Hash{} // topics are required when decoding
+		}
+	}
+
+	return json.Marshal(encodable)
+}
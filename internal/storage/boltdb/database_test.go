@@ -0,0 +1,223 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/binary"
+	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
+	"ethereum-tracker-app/pkg/tokentransfer"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestReloadAfterRestart(t *testing.T) {
+	windowSize := 3
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	conf := config.Config{
+		EthClientConf: config.EthClientConf{NumberOfRecentBlocks: windowSize},
+		StorageConf:   config.StorageConf{Backend: config.StorageBackendBolt, Path: filepath.Join(t.TempDir(), "test.db")},
+	}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	ctx := context.Background()
+
+	db, err := NewBoltDBService(conf, logger)
+	assert.NoError(t, err)
+
+	var lastBlock *types.Block
+	var block4Hash common.Hash
+	for number := uint64(1); number <= 5; number++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: number})
+		lastBlock = types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)}).
+			WithBody(types.Body{Transactions: types.Transactions{tx}})
+		txLog := &types.Log{Address: address, BlockNumber: number, BlockHash: lastBlock.Hash(), TxHash: tx.Hash()}
+		if number == 4 {
+			block4Hash = lastBlock.Hash()
+		}
+
+		assert.NoError(t, db.SetBlock(ctx, lastBlock))
+		assert.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{txLog}))
+		assert.NoError(t, db.SetLogByAddress(ctx, address.Hex(), txLog))
+		assert.NoError(t, db.SetReceipt(ctx, &types.Receipt{
+			Status: types.ReceiptStatusSuccessful, GasUsed: 21000, TxHash: tx.Hash(), BlockNumber: new(big.Int).SetUint64(number), Logs: []*types.Log{txLog},
+		}))
+		assert.NoError(t, db.SetAddressTransaction(ctx, &models.AddressTransaction{
+			Hash: tx.Hash().Hex(), BlockNumber: number, BlockHash: lastBlock.Hash().Hex(), From: address.Hex(), Value: "0",
+		}))
+	}
+
+	removedLogs, err := db.RollbackBlock(ctx, 5)
+	assert.NoError(t, err)
+	assert.Len(t, removedLogs, 1)
+	assert.NoError(t, db.SetBlockTag(ctx, models.BlockTagFinalized, 3))
+	assert.NoError(t, db.SetWebhook(ctx, models.Webhook{ID: "kept", Addresses: []common.Address{address}, Secret: "secret", Enabled: true}))
+	assert.NoError(t, db.SetWebhook(ctx, models.Webhook{ID: "deleted"}))
+	assert.NoError(t, db.DeleteWebhook(ctx, "deleted"))
+	deadLetters := []models.DeadLetter{{BlockNumber: 4, BlockHash: block4Hash.Hex(), TxHashes: []string{}, Attempts: 5, LastError: "receipt not available", FailedAt: time.Unix(1700000000, 0).UTC()}}
+	assert.NoError(t, db.SetDeadLetters(ctx, deadLetters))
+	assert.NoError(t, db.Close())
+
+	reopened, err := NewBoltDBService(conf, logger)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	for number := uint64(1); number <= 2; number++ {
+		_, err := reopened.GetBlockByNumber(ctx, number)
+		assert.Error(t, err, "block %d must be evicted", number)
+	}
+	block, err := reopened.GetBlockByNumber(ctx, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), block.NumberU64())
+	_, err = reopened.GetBlockByNumber(ctx, 5)
+	assert.Error(t, err, "rolled back block must not be reloaded")
+	_, err = reopened.GetBlockByHash(ctx, lastBlock.Hash())
+	assert.Error(t, err, "rolled back block must not be reloaded")
+
+	finalized, err := reopened.GetBlockTag(ctx, models.BlockTagFinalized)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), finalized)
+	latest, err := reopened.GetBlockTag(ctx, models.BlockTagLatest)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), latest)
+
+	txHash := block.Transactions()[0].Hash().Hex()
+	receipt, err := reopened.GetReceipt(ctx, txHash)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(21000), receipt.GasUsed)
+	_, index, err := reopened.GetBlockByTxHash(ctx, txHash)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	_, err = reopened.GetReceipt(ctx, lastBlock.Transactions()[0].Hash().Hex())
+	assert.Error(t, err, "receipt of a rolled back block must not be reloaded")
+
+	transactions, err := reopened.GetTransactionsByAddress(ctx, address.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2, "transactions of the evicted and the rolled back blocks must not be reloaded")
+
+	logs, err := reopened.GetLogsByAddress(ctx, address.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, logs, windowSize)
+	removed := 0
+	for _, txLog := range logs {
+		if txLog.Removed {
+			removed++
+			assert.Equal(t, lastBlock.Hash(), txLog.BlockHash)
+		}
+	}
+	assert.Equal(t, 1, removed)
+
+	webhooks, err := reopened.GetWebhooks(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, webhooks, 1) {
+		assert.Equal(t, "kept", webhooks[0].ID)
+		assert.Equal(t, "secret", webhooks[0].Secret, "the secret must be reloaded")
+		assert.Equal(t, []common.Address{address}, webhooks[0].Addresses)
+	}
+
+	reloadedDeadLetters, err := reopened.GetDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, deadLetters, reloadedDeadLetters)
+}
+
+func TestEvictionByStoredHead(t *testing.T) {
+	conf := config.Config{
+		EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 3},
+		StorageConf:   config.StorageConf{Backend: config.StorageBackendBolt, Path: filepath.Join(t.TempDir(), "test.db")},
+	}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	ctx := context.Background()
+
+	db, err := NewBoltDBService(conf, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the backfilled blocks come after the head, the old ones are not written and the recent ones do not evict the head
+	for _, number := range []uint64{7, 10, 9, 8, 5} {
+		tx := types.NewTx(&types.LegacyTx{Nonce: number})
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)}).
+			WithBody(types.Body{Transactions: types.Transactions{tx}})
+		assert.NoError(t, db.SetBlock(ctx, block))
+		assert.NoError(t, db.SetReceipt(ctx, &types.Receipt{TxHash: tx.Hash(), BlockNumber: new(big.Int).SetUint64(number), Logs: []*types.Log{}}))
+	}
+
+	stored := make(map[string][]uint64)
+	assert.NoError(t, db.(*boltDB).db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, receiptsBucket} {
+			err := tx.Bucket(name).ForEach(func(key, _ []byte) error {
+				stored[string(name)] = append(stored[string(name)], binary.BigEndian.Uint64(key[:8]))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	assert.Equal(t, []uint64{8, 9, 10}, stored[string(blocksBucket)])
+	assert.Equal(t, []uint64{8, 9, 10}, stored[string(receiptsBucket)])
+}
+
+func TestReingestAfterRestart(t *testing.T) {
+	conf := config.Config{StorageConf: config.StorageConf{Backend: config.StorageBackendBolt, Path: filepath.Join(t.TempDir(), "test.db")}}
+	logger := log.New(os.Stdout, "app", log.LstdFlags)
+	ctx := context.Background()
+
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	from := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	to := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5")
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, To: &token})
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(types.Body{Transactions: types.Transactions{tx}})
+	txLog := &types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:        common.BigToHash(big.NewInt(1000)).Bytes(),
+		BlockNumber: 1,
+		BlockHash:   block.Hash(),
+		TxHash:      tx.Hash(),
+	}
+	transaction := &models.AddressTransaction{Hash: tx.Hash().Hex(), BlockNumber: 1, BlockHash: block.Hash().Hex(), From: from.Hex(), To: token.Hex(), Value: "0"}
+
+	// the block is stored before and after a restart, as it was in flight
+	for i := 0; i < 2; i++ {
+		db, err := NewBoltDBService(conf, logger)
+		assert.NoError(t, err)
+
+		assert.NoError(t, db.SetBlock(ctx, block))
+		assert.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{txLog}))
+		assert.NoError(t, db.SetLogByAddress(ctx, token.Hex(), txLog))
+		for _, transfer := range tokentransfer.Parse(*txLog) {
+			assert.NoError(t, db.SetTransfer(ctx, &transfer))
+		}
+		assert.NoError(t, db.SetAddressTransaction(ctx, transaction))
+		assert.NoError(t, db.Close())
+	}
+
+	reopened, err := NewBoltDBService(conf, logger)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	logs, err := reopened.GetLogsByAddress(ctx, token.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	logs, err = reopened.GetLogsByAddressInRange(ctx, to.Hex(), models.AddressRoleParticipant, 0, 1)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 1)
+	transfers, err := reopened.GetTransfersByAddress(ctx, to.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 1)
+	transactions, err := reopened.GetTransactionsByAddress(ctx, from.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+}
@@ -2,29 +2,65 @@
 Get and store the block and all transaction hashes in the block
 Get and store all events related to each transaction in each block
 
-To keep the storage of 50 blocks updated, in case you want strictly keep only 50 blocks and not more, as a new block comes, then the oldest block will be deleted
-This can be done easily by the blocknumber itself and no need to store the data in dubly link-list. Here for simplicity, and also flexibility to extend the project
-I assumed that new blocks simply just be added (not deleting oldest block).
+To keep the storage bounded, only the most recent NUMBER_OF_RECENT_BLOCKS blocks are kept. As a new block comes, every block which falls out
+of the window is evicted together with its transaction hashes and logs. This is done by the blocknumber itself and no need to store the data in
+dubly link-list. A window size of zero disables the eviction.
 */
 package inmemorydb
 
 import (
 	"context"
 	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
 	"ethereum-tracker-app/pkg/customerror"
+	"ethereum-tracker-app/pkg/topicaddress"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
 type Service interface {
 	SetBlock(ctx context.Context, block *types.Block) error
-	SetTransactionHash(ctx context.Context, blockNumber uint64, txHashHex string) error
+	SetTransactionHashes(ctx context.Context, blockNumber uint64, txHashesHex []string) error
 	SetLogsByTx(ctx context.Context, txHashHex string, logs []*types.Log) error
 	SetLogByAddress(ctx context.Context, addressHex string, log *types.Log) error
+	SetReceipt(ctx context.Context, receipt *types.Receipt) error
+	GetReceipt(ctx context.Context, txHashHex string) (*types.Receipt, error)
+	GetLogsByTx(ctx context.Context, txHashHex string) ([]types.Log, error)
+	GetBlockByTxHash(ctx context.Context, txHashHex string) (*types.Block, int, error)
 	GetLogsByAddress(ctx context.Context, addressHex string) ([]types.Log, error)
+	SetTransfer(ctx context.Context, transfer *models.Transfer) error
+	GetTransfersByAddress(ctx context.Context, addressHex string) ([]models.Transfer, error)
+	GetTransfersByToken(ctx context.Context, tokenHex string) ([]models.Transfer, error)
+	SetAddressTransaction(ctx context.Context, transaction *models.AddressTransaction) error
+	GetTransactionsByAddress(ctx context.Context, addressHex string) ([]models.AddressTransaction, error)
+	GetLogsByAddressInRange(ctx context.Context, addressHex string, role models.AddressRole, fromBlock, toBlock uint64) ([]types.Log, error)
+	GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error)
+	GetBlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	GetLogsByBlock(ctx context.Context, number uint64) ([]types.Log, error)
+	GetTransactionHashes(ctx context.Context, blockNumber uint64) ([]string, error)
+	RollbackBlock(ctx context.Context, number uint64) ([]types.Log, error)
+	SetCheckpoint(ctx context.Context, number uint64) error
+	GetCheckpoint(ctx context.Context) (uint64, error)
+	SetBlockTag(ctx context.Context, tag models.BlockTag, number uint64) error
+	GetBlockTag(ctx context.Context, tag models.BlockTag) (uint64, error)
+	SetWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	SetDeadLetters(ctx context.Context, deadLetters []models.DeadLetter) error
+	GetDeadLetters(ctx context.Context) ([]models.DeadLetter, error)
+	Close() error
+}
+
+// txLocation is the position of a transaction in the chain
+type txLocation struct {
+	blockNumber uint64
+	index       int
 }
 
 type inmemoryDB struct {
@@ -32,10 +68,34 @@ type inmemoryDB struct {
 	logger *log.Logger
 
 	mu          sync.RWMutex
+	head        uint64 // the highest block number stored so far
+	checkpoint  *uint64
 	blocks      map[uint64]*types.Block
-	txHashes    map[uint64]string
+	blockHashes map[common.Hash]uint64
+	tags        map[models.BlockTag]uint64 // the safe and finalized block numbers reported by the node
+	txHashes    map[uint64][]string
 	txLogs      map[string][]*types.Log
+	txIndex     map[string]txLocation     // transaction hash -> position in its block
+	receipts    map[string]*types.Receipt // transaction hash -> receipt, without the logs which are kept in txLogs
 	addressLogs map[string][]*types.Log
+
+	blockAddresses map[uint64]map[string]struct{} // addresses having logs in a block, to find the logs of a block without scanning all addresses
+
+	participantLogs   map[string][]*types.Log        // address appearing in the indexed topics -> logs, a secondary index of the logs
+	blockParticipants map[uint64]map[string]struct{} // addresses appearing in the indexed topics of the logs of a block
+
+	addressTransfers       map[string][]*models.Transfer  // sender or recipient -> token transfers
+	blockTransferAddresses map[uint64]map[string]struct{} // addresses having token transfers in a block
+	tokenTransfers         map[string][]*models.Transfer  // token contract -> token transfers
+	blockTransferTokens    map[uint64]map[string]struct{} // token contracts having transfers in a block
+
+	addressTransactions       map[string][]*models.AddressTransaction // sender or recipient -> transactions
+	blockTransactionAddresses map[uint64]map[string]struct{}          // addresses having transactions in a block
+
+	indexed map[uint64]map[string]struct{} // keys of the logs, transfers and transactions indexed by address in a block
+
+	webhooks    map[string]models.Webhook // by id, not bounded by the window
+	deadLetters []models.DeadLetter       // the blocks whose retries are exhausted, not bounded by the window
 }
 
 func NewInmemortDBService(config config.Config, logger *log.Logger) Service {
@@ -44,58 +104,270 @@ func NewInmemortDBService(config config.Config, logger *log.Logger) Service {
 		logger:      logger,
 		mu:          sync.RWMutex{},
 		blocks:      make(map[uint64]*types.Block),
-		txHashes:    make(map[uint64]string),
+		blockHashes: make(map[common.Hash]uint64),
+		tags:        make(map[models.BlockTag]uint64),
+		txHashes:    make(map[uint64][]string),
 		txLogs:      make(map[string][]*types.Log),
+		txIndex:     make(map[string]txLocation),
+		receipts:    make(map[string]*types.Receipt),
 		addressLogs: make(map[string][]*types.Log),
+
+		blockAddresses: make(map[uint64]map[string]struct{}),
+
+		participantLogs:   make(map[string][]*types.Log),
+		blockParticipants: make(map[uint64]map[string]struct{}),
+
+		addressTransfers:       make(map[string][]*models.Transfer),
+		blockTransferAddresses: make(map[uint64]map[string]struct{}),
+		tokenTransfers:         make(map[string][]*models.Transfer),
+		blockTransferTokens:    make(map[uint64]map[string]struct{}),
+
+		addressTransactions:       make(map[string][]*models.AddressTransaction),
+		blockTransactionAddresses: make(map[uint64]map[string]struct{}),
+
+		indexed: make(map[uint64]map[string]struct{}),
+
+		webhooks: make(map[string]models.Webhook),
 	}
 }
 
-// SetBlock gett and stores the block in database
+// SetBlock gets and stores the block in database and evicts the blocks which fall out of the window
 func (db *inmemoryDB) SetBlock(ctx context.Context, block *types.Block) error {
 	db.mu.Lock()
-	db.blocks[block.NumberU64()] = block
-	db.mu.Unlock()
+	defer db.mu.Unlock()
+
+	number := block.NumberU64()
+	if db.isOutOfWindow(number) {
+		db.logger.Printf("block %d is older than the window of the recent blocks, skipped", number)
+		return nil
+	}
+
+	replaced, ok := db.blocks[number]
+	if ok && replaced.Hash() == block.Hash() {
+		return nil // stored again, e.g. fetched again after a restart
+	}
+	if ok {
+		delete(db.blockHashes, replaced.Hash())
+		db.unindexTransactions(replaced)
+	}
+	db.blocks[number] = block
+	db.blockHashes[block.Hash()] = number
+	for i, tx := range block.Transactions() {
+		db.txIndex[tx.Hash().Hex()] = txLocation{blockNumber: number, index: i}
+	}
+	if number > db.head {
+		db.head = number
+		db.evictOldBlocks()
+	}
 
 	return nil
 }
 
-// SetTransactio sets transaction hashes in the database
-func (db *inmemoryDB) SetTransactionHash(ctx context.Context, blockNumber uint64, txHashHex string) error {
+// SetTransactionHashes sets the hashes of all the transactions of a block in the database, in their order in the block
+func (db *inmemoryDB) SetTransactionHashes(ctx context.Context, blockNumber uint64, txHashesHex []string) error {
 	db.mu.Lock()
-	db.txHashes[blockNumber] = txHashHex
-	db.mu.Unlock()
+	defer db.mu.Unlock()
+
+	if db.isOutOfWindow(blockNumber) {
+		return nil
+	}
+	db.txHashes[blockNumber] = slices.Clone(txHashesHex)
 
 	return nil
 }
 
+// GetTransactionHashes gets the hashes of all the transactions of a block, in their order in the block
+func (db *inmemoryDB) GetTransactionHashes(ctx context.Context, blockNumber uint64) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	txHashesHex, ok := db.txHashes[blockNumber]
+	if !ok {
+		return nil, customerror.NewNotFoundError("block does not exist", fmt.Errorf("transaction hashes of block %d are not stored", blockNumber))
+	}
+
+	return slices.Clone(txHashesHex), nil
+}
+
 // SetLogsByTx stores all events related to each transaction in each block
 func (db *inmemoryDB) SetLogsByTx(ctx context.Context, txHashHex string, logs []*types.Log) error {
 	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(logs) != 0 && db.isOutOfWindow(logs[0].BlockNumber) {
+		return nil
+	}
 	db.txLogs[txHashHex] = logs
-	db.mu.Unlock()
 
 	return nil
 }
 
-// SetLogByAddress stores the log related to an address
+// SetLogByAddress stores the log related to an address, the emitter of the log. The log is indexed by the addresses
+// appearing in its indexed topics as well. Storing the same log again is a no-op
 func (db *inmemoryDB) SetLogByAddress(ctx context.Context, addressHex string, log *types.Log) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.isOutOfWindow(log.BlockNumber) || !db.markIndexed(log.BlockNumber, logKey(log)) {
+		return nil
+	}
+	db.addressLogs[addressHex] = append(db.addressLogs[addressHex], log)
+
+	if _, ok := db.blockAddresses[log.BlockNumber]; !ok {
+		db.blockAddresses[log.BlockNumber] = make(map[string]struct{})
+	}
+	db.blockAddresses[log.BlockNumber][addressHex] = struct{}{}
+
+	for _, participantHex := range topicaddress.Parse(log) {
+		db.participantLogs[participantHex] = append(db.participantLogs[participantHex], log)
+
+		if _, ok := db.blockParticipants[log.BlockNumber]; !ok {
+			db.blockParticipants[log.BlockNumber] = make(map[string]struct{})
+		}
+		db.blockParticipants[log.BlockNumber][participantHex] = struct{}{}
+	}
+
+	return nil
+}
+
+// SetReceipt stores the receipt of a transaction. Its logs are stored apart by SetLogsByTx
+func (db *inmemoryDB) SetReceipt(ctx context.Context, receipt *types.Receipt) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.isOutOfWindow(receipt.BlockNumber.Uint64()) {
+		return nil
+	}
+
+	withoutLogs := *receipt
+	withoutLogs.Logs = nil
+	db.receipts[receipt.TxHash.Hex()] = &withoutLogs
+
+	return nil
+}
+
+// GetReceipt gets the receipt of a transaction, without its logs
+func (db *inmemoryDB) GetReceipt(ctx context.Context, txHashHex string) (*types.Receipt, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	receipt, ok := db.receipts[txHashHex]
+	if !ok {
+		return nil, customerror.NewNotFoundError("receipt does not exist", fmt.Errorf("receipt of transaction %s is not stored", txHashHex))
+	}
+	withoutLogs := *receipt
+
+	return &withoutLogs, nil
+}
+
+// GetLogsByTx gets all the Logs emitted by a transaction, none if it did not emit any
+func (db *inmemoryDB) GetLogsByTx(ctx context.Context, txHashHex string) ([]types.Log, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return returnByValue(db.txLogs[txHashHex]), nil
+}
+
+// GetBlockByTxHash gets the stored block including a transaction, and the index of the transaction in the block
+func (db *inmemoryDB) GetBlockByTxHash(ctx context.Context, txHashHex string) (*types.Block, int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	location, ok := db.txIndex[txHashHex]
+	if !ok {
+		return nil, 0, customerror.NewNotFoundError("transaction does not exist", fmt.Errorf("transaction %s is not stored", txHashHex))
+	}
+
+	return db.blocks[location.blockNumber], location.index, nil
+}
+
+// SetTransfer stores a token transfer, indexed by its sender, its recipient and its token. Storing the same transfer again is a no-op
+func (db *inmemoryDB) SetTransfer(ctx context.Context, transfer *models.Transfer) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.isOutOfWindow(transfer.BlockNumber) || !db.markIndexed(transfer.BlockNumber, transferKey(transfer)) {
+		return nil
+	}
+
+	if _, ok := db.blockTransferAddresses[transfer.BlockNumber]; !ok {
+		db.blockTransferAddresses[transfer.BlockNumber] = make(map[string]struct{})
+	}
+	participants := []string{transfer.From}
+	if transfer.To != transfer.From {
+		participants = append(participants, transfer.To) // a transfer to self is indexed once
+	}
+	for _, addressHex := range participants {
+		db.addressTransfers[addressHex] = append(db.addressTransfers[addressHex], transfer)
+		db.blockTransferAddresses[transfer.BlockNumber][addressHex] = struct{}{}
+	}
+
+	if _, ok := db.blockTransferTokens[transfer.BlockNumber]; !ok {
+		db.blockTransferTokens[transfer.BlockNumber] = make(map[string]struct{})
+	}
+	db.tokenTransfers[transfer.Token] = append(db.tokenTransfers[transfer.Token], transfer)
+	db.blockTransferTokens[transfer.BlockNumber][transfer.Token] = struct{}{}
+
+	return nil
+}
+
+// GetTransfersByAddress gets the token transfers sent or received by an address, none if it has not any
+func (db *inmemoryDB) GetTransfersByAddress(ctx context.Context, addressHex string) ([]models.Transfer, error) {
 	db.mu.RLock()
-	logs := db.addressLogs[addressHex]
-	db.mu.RUnlock()
+	defer db.mu.RUnlock()
 
+	return returnByValue(db.addressTransfers[addressHex]), nil
+}
+
+// GetTransfersByToken gets the transfers of a token contract, none if it has not any
+func (db *inmemoryDB) GetTransfersByToken(ctx context.Context, tokenHex string) ([]models.Transfer, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return returnByValue(db.tokenTransfers[tokenHex]), nil
+}
+
+// SetAddressTransaction stores a transaction, indexed by both its sender and its recipient. The recipient of a contract
+// creation is the created contract. Storing the same transaction again is a no-op
+func (db *inmemoryDB) SetAddressTransaction(ctx context.Context, transaction *models.AddressTransaction) error {
 	db.mu.Lock()
-	logs = append(logs, log)
-	db.addressLogs[addressHex] = logs
-	db.mu.Unlock()
+	defer db.mu.Unlock()
+
+	if db.isOutOfWindow(transaction.BlockNumber) || !db.markIndexed(transaction.BlockNumber, transactionKey(transaction.BlockHash, transaction.TransactionIndex)) {
+		return nil
+	}
+
+	if _, ok := db.blockTransactionAddresses[transaction.BlockNumber]; !ok {
+		db.blockTransactionAddresses[transaction.BlockNumber] = make(map[string]struct{})
+	}
+	participants := []string{transaction.From}
+	for _, recipient := range []string{transaction.To, transaction.ContractAddress} {
+		if recipient != "" && recipient != transaction.From {
+			participants = append(participants, recipient) // a transaction to self is indexed once
+		}
+	}
+	for _, addressHex := range participants {
+		db.addressTransactions[addressHex] = append(db.addressTransactions[addressHex], transaction)
+		db.blockTransactionAddresses[transaction.BlockNumber][addressHex] = struct{}{}
+	}
 
 	return nil
 }
 
+// GetTransactionsByAddress gets the transactions sent or received by an address, none if it has not any
+func (db *inmemoryDB) GetTransactionsByAddress(ctx context.Context, addressHex string) ([]models.AddressTransaction, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return returnByValue(db.addressTransactions[addressHex]), nil
+}
+
 // GetLogsByAddress gets all the Logs related to an address
 func (db *inmemoryDB) GetLogsByAddress(ctx context.Context, addressHex string) ([]types.Log, error) {
 	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	logs, ok := db.addressLogs[addressHex]
-	db.mu.RUnlock()
 	if !ok {
 		return nil, customerror.NewStorageError("log does not exist", fmt.Errorf("no logs exists for address %s", addressHex))
 	}
@@ -103,6 +375,421 @@ func (db *inmemoryDB) GetLogsByAddress(ctx context.Context, addressHex string) (
 	return returnByValue(logs), nil
 }
 
+// GetLogsByAddressInRange gets the Logs related to an address by its role, emitted in the blocks from fromBlock to toBlock inclusive.
+// The address is the emitter of the logs, or a participant appearing in their indexed topics, or any of them. An address without logs has none
+func (db *inmemoryDB) GetLogsByAddressInRange(ctx context.Context, addressHex string, role models.AddressRole, fromBlock, toBlock uint64) ([]types.Log, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	indexes := make([][]*types.Log, 0, 2)
+	if logs, ok := db.addressLogs[addressHex]; ok && role != models.AddressRoleParticipant {
+		indexes = append(indexes, logs)
+	}
+	if logs, ok := db.participantLogs[addressHex]; ok && role != models.AddressRoleEmitter {
+		indexes = append(indexes, logs)
+	}
+
+	// a log emitted by the address may refer to the address in its topics too
+	seen := make(map[*types.Log]struct{})
+	inRange := make([]types.Log, 0)
+	for _, logs := range indexes {
+		for _, txLog := range logs {
+			if _, ok := seen[txLog]; ok || txLog.BlockNumber < fromBlock || txLog.BlockNumber > toBlock {
+				continue
+			}
+			seen[txLog] = struct{}{}
+			inRange = append(inRange, *txLog)
+		}
+	}
+
+	return inRange, nil
+}
+
+// GetBlockByNumber gets a stored block by its number
+func (db *inmemoryDB) GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	block, ok := db.blocks[number]
+	if !ok {
+		return nil, customerror.NewNotFoundError("block does not exist", fmt.Errorf("block %d is not stored", number))
+	}
+
+	return block, nil
+}
+
+// GetBlockByHash gets a stored block by its hash
+func (db *inmemoryDB) GetBlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	number, ok := db.blockHashes[hash]
+	if !ok {
+		return nil, customerror.NewNotFoundError("block does not exist", fmt.Errorf("block %s is not stored", hash.Hex()))
+	}
+
+	return db.blocks[number], nil
+}
+
+// GetLogsByBlock gets all the Logs emitted in a stored block, in the order of its transactions
+func (db *inmemoryDB) GetLogsByBlock(ctx context.Context, number uint64) ([]types.Log, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	block, ok := db.blocks[number]
+	if !ok {
+		return nil, customerror.NewNotFoundError("block does not exist", fmt.Errorf("block %d is not stored", number))
+	}
+
+	logs := make([]types.Log, 0)
+	for _, tx := range block.Transactions() {
+		logs = append(logs, returnByValue(db.txLogs[tx.Hash().Hex()])...)
+	}
+
+	return logs, nil
+}
+
+// RollbackBlock removes an orphaned block after a chain reorganization. Its logs are kept in the address index flagged
+// as removed, the same as types.Log Removed semantics, so the consumers can see them. Its transactions are unindexed, as
+// they are not part of the chain anymore. The removed logs are returned
+func (db *inmemoryDB) RollbackBlock(ctx context.Context, number uint64) ([]types.Log, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	block, ok := db.blocks[number]
+	if !ok {
+		return nil, customerror.NewNotFoundError("block does not exist", fmt.Errorf("block %d is not stored", number))
+	}
+
+	blockHash := block.Hash()
+	removedLogs := make([]types.Log, 0)
+	replaced := make(map[*types.Log]*types.Log) // the same removed copy replaces a log in both address indexes
+	for addressHex := range db.blockAddresses[number] {
+		for i, txLog := range db.addressLogs[addressHex] {
+			if txLog.BlockHash != blockHash || txLog.Removed {
+				continue
+			}
+
+			// the stored log is replaced, not modified, as the same pointer is shared by the transaction index
+			removedLog := *txLog
+			removedLog.Removed = true
+			db.addressLogs[addressHex][i] = &removedLog
+			replaced[txLog] = &removedLog
+			removedLogs = append(removedLogs, removedLog)
+			db.reindex(number, logKey(txLog), logKey(&removedLog))
+		}
+	}
+	for participantHex := range db.blockParticipants[number] {
+		for i, txLog := range db.participantLogs[participantHex] {
+			if removedLog, ok := replaced[txLog]; ok {
+				db.participantLogs[participantHex][i] = removedLog
+			}
+		}
+	}
+
+	replacedTransfers := make(map[*models.Transfer]*models.Transfer) // the same removed copy replaces a transfer in every index
+	for addressHex := range db.blockTransferAddresses[number] {
+		for i, transfer := range db.addressTransfers[addressHex] {
+			if transfer.BlockHash != blockHash.Hex() || transfer.Removed {
+				continue
+			}
+
+			removedTransfer, ok := replacedTransfers[transfer]
+			if !ok {
+				removedTransfer = new(models.Transfer)
+				*removedTransfer = *transfer
+				removedTransfer.Removed = true
+				replacedTransfers[transfer] = removedTransfer
+				db.reindex(number, transferKey(transfer), transferKey(removedTransfer))
+			}
+			db.addressTransfers[addressHex][i] = removedTransfer
+		}
+	}
+	for tokenHex := range db.blockTransferTokens[number] {
+		for i, transfer := range db.tokenTransfers[tokenHex] {
+			if removedTransfer, ok := replacedTransfers[transfer]; ok {
+				db.tokenTransfers[tokenHex][i] = removedTransfer
+			}
+		}
+	}
+
+	for i, tx := range block.Transactions() {
+		delete(db.txLogs, tx.Hash().Hex())
+		delete(db.indexed[number], transactionKey(blockHash.Hex(), i))
+	}
+	db.unindexTransactions(block)
+	db.evictBlockTransactions(number)
+	delete(db.txHashes, number)
+	delete(db.blockHashes, blockHash)
+	delete(db.blocks, number)
+
+	if number == db.head {
+		db.head = 0
+		for stored := range db.blocks {
+			db.head = max(db.head, stored)
+		}
+	}
+
+	return removedLogs, nil
+}
+
+// SetCheckpoint stores the last block number up to which all the blocks are fully processed
+func (db *inmemoryDB) SetCheckpoint(ctx context.Context, number uint64) error {
+	db.mu.Lock()
+	db.checkpoint = &number
+	db.mu.Unlock()
+
+	return nil
+}
+
+// GetCheckpoint gets the last block number up to which all the blocks are fully processed
+func (db *inmemoryDB) GetCheckpoint(ctx context.Context) (uint64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.checkpoint == nil {
+		return 0, customerror.NewNotFoundError("checkpoint does not exist", nil)
+	}
+
+	return *db.checkpoint, nil
+}
+
+// SetBlockTag stores the block number of a tag, like the safe or the finalized block reported by the node
+func (db *inmemoryDB) SetBlockTag(ctx context.Context, tag models.BlockTag, number uint64) error {
+	db.mu.Lock()
+	db.tags[tag] = number
+	db.mu.Unlock()
+
+	return nil
+}
+
+// GetBlockTag gets the block number of a tag. The latest block is the highest stored one
+func (db *inmemoryDB) GetBlockTag(ctx context.Context, tag models.BlockTag) (uint64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if tag == models.BlockTagLatest {
+		if len(db.blocks) == 0 {
+			return 0, customerror.NewNotFoundError("no block is stored yet", nil)
+		}
+		return db.head, nil
+	}
+
+	number, ok := db.tags[tag]
+	if !ok {
+		return 0, customerror.NewNotFoundError(fmt.Sprintf("%s block is not known yet", tag), nil)
+	}
+
+	return number, nil
+}
+
+// SetWebhook stores a webhook, replacing the previous state of the same id
+func (db *inmemoryDB) SetWebhook(ctx context.Context, webhook models.Webhook) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+// GetWebhooks gets all the stored webhooks, in the order of their registration
+func (db *inmemoryDB) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	webhooks := make([]models.Webhook, 0, len(db.webhooks))
+	for _, webhook := range db.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	slices.SortFunc(webhooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a stored webhook
+func (db *inmemoryDB) DeleteWebhook(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.webhooks[id]; !ok {
+		return customerror.NewNotFoundError("webhook does not exist", fmt.Errorf("webhook %s is not stored", id))
+	}
+	delete(db.webhooks, id)
+
+	return nil
+}
+
+// SetDeadLetters stores the dead letters of the retries, replacing the previous ones
+func (db *inmemoryDB) SetDeadLetters(ctx context.Context, deadLetters []models.DeadLetter) error {
+	db.mu.Lock()
+	db.deadLetters = slices.Clone(deadLetters)
+	db.mu.Unlock()
+
+	return nil
+}
+
+// GetDeadLetters gets the stored dead letters of the retries
+func (db *inmemoryDB) GetDeadLetters(ctx context.Context) ([]models.DeadLetter, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return slices.Clone(db.deadLetters), nil
+}
+
+// Close releases the resources of the database, nothing to release for the in-memory one
+func (db *inmemoryDB) Close() error {
+	return nil
+}
+
+// isOutOfWindow reports whether a block number is already older than the window of the recent blocks. The caller must hold the lock
+func (db *inmemoryDB) isOutOfWindow(number uint64) bool {
+	windowSize := uint64(db.config.EthClientConf.NumberOfRecentBlocks)
+	if windowSize == 0 {
+		return false
+	}
+
+	return number+windowSize <= db.head
+}
+
+// evictOldBlocks removes the blocks which fell out of the window, with all their transaction hashes and logs. The caller must hold the lock
+func (db *inmemoryDB) evictOldBlocks() {
+	for number := range db.blocks {
+		if db.isOutOfWindow(number) {
+			db.evictBlock(number)
+		}
+	}
+
+	// logs, transfers and transactions of rolled back blocks may remain without a stored block
+	for number := range db.blockAddresses {
+		if db.isOutOfWindow(number) {
+			db.evictBlock(number)
+		}
+	}
+	for number := range db.blockTransferAddresses {
+		if db.isOutOfWindow(number) {
+			db.evictBlock(number)
+		}
+	}
+	for number := range db.blockTransactionAddresses {
+		if db.isOutOfWindow(number) {
+			db.evictBlock(number)
+		}
+	}
+}
+
+// evictBlock removes a block and every entry related to it. The caller must hold the lock
+func (db *inmemoryDB) evictBlock(number uint64) {
+	if block, ok := db.blocks[number]; ok {
+		delete(db.blockHashes, block.Hash())
+		for _, tx := range block.Transactions() {
+			delete(db.txLogs, tx.Hash().Hex())
+		}
+		db.unindexTransactions(block)
+	}
+
+	evictBlockEntries(db.addressLogs, db.blockAddresses[number], number, logBlockNumber)
+	delete(db.blockAddresses, number)
+	evictBlockEntries(db.participantLogs, db.blockParticipants[number], number, logBlockNumber)
+	delete(db.blockParticipants, number)
+	evictBlockEntries(db.addressTransfers, db.blockTransferAddresses[number], number, transferBlockNumber)
+	delete(db.blockTransferAddresses, number)
+	evictBlockEntries(db.tokenTransfers, db.blockTransferTokens[number], number, transferBlockNumber)
+	delete(db.blockTransferTokens, number)
+	db.evictBlockTransactions(number)
+	delete(db.indexed, number)
+	delete(db.txHashes, number)
+	delete(db.blocks, number)
+}
+
+// evictBlockTransactions removes the transactions of a block from the address index. The caller must hold the lock
+func (db *inmemoryDB) evictBlockTransactions(number uint64) {
+	evictBlockEntries(db.addressTransactions, db.blockTransactionAddresses[number], number, transactionBlockNumber)
+	delete(db.blockTransactionAddresses, number)
+}
+
+// evictBlockEntries removes the entries of a block from an address index, given the addresses having entries in the block and
+// the block number of an entry. The caller must hold the lock
+func evictBlockEntries[T any](index map[string][]*T, addresses map[string]struct{}, number uint64, blockNumber func(*T) uint64) {
+	for addressHex := range addresses {
+		remained := index[addressHex][:0]
+		for _, entry := range index[addressHex] {
+			if blockNumber(entry) != number {
+				remained = append(remained, entry)
+			}
+		}
+
+		if len(remained) == 0 {
+			delete(index, addressHex)
+			continue
+		}
+		index[addressHex] = remained
+	}
+}
+
+func logBlockNumber(txLog *types.Log) uint64 {
+	return txLog.BlockNumber
+}
+
+func transferBlockNumber(transfer *models.Transfer) uint64 {
+	return transfer.BlockNumber
+}
+
+func transactionBlockNumber(transaction *models.AddressTransaction) uint64 {
+	return transaction.BlockNumber
+}
+
+// unindexTransactions removes the transactions of a block from the transaction index, with their receipts. The transactions
+// which were included again in another block are kept. The caller must hold the lock
+func (db *inmemoryDB) unindexTransactions(block *types.Block) {
+	for _, tx := range block.Transactions() {
+		txHashHex := tx.Hash().Hex()
+		if location, ok := db.txIndex[txHashHex]; ok && location.blockNumber == block.NumberU64() {
+			delete(db.txIndex, txHashHex)
+			delete(db.receipts, txHashHex)
+		}
+	}
+}
+
+// markIndexed records the key of an entry indexed by address in a block. It reports false if the entry is indexed already, so the
+// same data stored again, e.g. a block fetched again after a restart, is not duplicated. The caller must hold the lock
+func (db *inmemoryDB) markIndexed(number uint64, key string) bool {
+	keys, ok := db.indexed[number]
+	if !ok {
+		keys = make(map[string]struct{})
+		db.indexed[number] = keys
+	}
+	if _, ok := keys[key]; ok {
+		return false
+	}
+	keys[key] = struct{}{}
+
+	return true
+}
+
+// reindex replaces the key of an entry indexed in a block, like a log flagged as removed. The caller must hold the lock
+func (db *inmemoryDB) reindex(number uint64, oldKey, newKey string) {
+	delete(db.indexed[number], oldKey)
+	db.markIndexed(number, newKey)
+}
+
+func logKey(txLog *types.Log) string {
+	return fmt.Sprintf("log/%s/%s/%d/%t", txLog.BlockHash.Hex(), txLog.TxHash.Hex(), txLog.Index, txLog.Removed)
+}
+
+func transferKey(transfer *models.Transfer) string {
+	return fmt.Sprintf("transfer/%s/%s/%d/%d/%t", transfer.BlockHash, transfer.TxHash, transfer.LogIndex, transfer.BatchIndex, transfer.Removed)
+}
+
+func transactionKey(blockHashHex string, transactionIndex int) string {
+	return fmt.Sprintf("tx/%s/%d", blockHashHex, transactionIndex)
+}
+
 // purpose: safety. blocking the consumer of above functions to unintentionally modify the datastorage, which in this specific case is a map
 func returnByValue[k any](input []*k) []k {
 	output := make([]k, len(input))
@@ -3,6 +3,7 @@ package inmemorydb
 import (
 	"context"
 	"ethereum-tracker-app/cmd/config"
+	"ethereum-tracker-app/models"
 	"fmt"
 	"log"
 	"math/big"
@@ -10,6 +11,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -122,3 +124,212 @@ func TestSetBlock(t *testing.T) {
 		assert.Equal(t, tt.isInserted, ok)
 	}
 }
+
+func TestBlockWindowEviction(t *testing.T) {
+	windowSize := 3
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: windowSize}}
+	db := NewInmemortDBService(conf, log.New(os.Stdout, "app", log.LstdFlags)).(*inmemoryDB)
+	ctx := context.Background()
+
+	for number := uint64(1); number <= 5; number++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: number})
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)}).
+			WithBody(types.Body{Transactions: types.Transactions{tx}})
+		txLog := &types.Log{Address: address, BlockNumber: number, TxHash: tx.Hash()}
+
+		assert.NoError(t, db.SetBlock(ctx, block))
+		assert.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{txLog}))
+		assert.NoError(t, db.SetLogByAddress(ctx, address.Hex(), txLog))
+	}
+
+	assert.Len(t, db.blocks, windowSize)
+	assert.Len(t, db.txLogs, windowSize)
+	for number := uint64(1); number <= 2; number++ {
+		_, ok := db.blocks[number]
+		assert.False(t, ok, "block %d must be evicted", number)
+	}
+
+	logs, err := db.GetLogsByAddress(ctx, address.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, logs, windowSize)
+	for _, txLog := range logs {
+		assert.GreaterOrEqual(t, txLog.BlockNumber, uint64(3))
+	}
+	assert.Len(t, db.blockHashes, windowSize)
+
+	logs, err = db.GetLogsByAddressInRange(ctx, address.Hex(), models.AddressRoleEmitter, 4, 10)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+
+	// a block which is already older than the window must not be stored
+	assert.NoError(t, db.SetBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})))
+	_, ok := db.blocks[1]
+	assert.False(t, ok)
+}
+
+func TestRollbackBlock(t *testing.T) {
+	address := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 50}}
+	db := NewInmemortDBService(conf, log.New(os.Stdout, "app", log.LstdFlags)).(*inmemoryDB)
+	ctx := context.Background()
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1})
+	orphan := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10), Extra: []byte("orphan")}).
+		WithBody(types.Body{Transactions: types.Transactions{tx}})
+	orphanLog := &types.Log{Address: address, BlockNumber: 10, BlockHash: orphan.Hash(), TxHash: tx.Hash()}
+	assert.NoError(t, db.SetBlock(ctx, orphan))
+	assert.NoError(t, db.SetLogsByTx(ctx, tx.Hash().Hex(), []*types.Log{orphanLog}))
+	assert.NoError(t, db.SetLogByAddress(ctx, address.Hex(), orphanLog))
+
+	removedLogs, err := db.RollbackBlock(ctx, 10)
+	assert.NoError(t, err)
+	assert.Len(t, removedLogs, 1)
+	assert.True(t, removedLogs[0].Removed)
+	assert.False(t, orphanLog.Removed, "the stored log must not be modified in place")
+
+	_, err = db.GetBlockByNumber(ctx, 10)
+	assert.Error(t, err)
+	_, ok := db.txLogs[tx.Hash().Hex()]
+	assert.False(t, ok)
+
+	canonical := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10), Extra: []byte("canonical")}).
+		WithBody(types.Body{Transactions: types.Transactions{tx}})
+	canonicalLog := &types.Log{Address: address, BlockNumber: 10, BlockHash: canonical.Hash(), TxHash: tx.Hash()}
+	assert.NoError(t, db.SetBlock(ctx, canonical))
+	assert.NoError(t, db.SetLogByAddress(ctx, address.Hex(), canonicalLog))
+
+	logs, err := db.GetLogsByAddress(ctx, address.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.True(t, logs[0].Removed)
+	assert.Equal(t, orphan.Hash(), logs[0].BlockHash)
+	assert.False(t, logs[1].Removed)
+	assert.Equal(t, canonical.Hash(), logs[1].BlockHash)
+
+	_, err = db.RollbackBlock(ctx, 11)
+	assert.Error(t, err)
+}
+
+func TestTransferIndex(t *testing.T) {
+	sender := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297").Hex()
+	recipient := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5").Hex()
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7").Hex()
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 2}}
+	db := NewInmemortDBService(conf, log.New(os.Stdout, "app", log.LstdFlags)).(*inmemoryDB)
+	ctx := context.Background()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	assert.NoError(t, db.SetBlock(ctx, block))
+	assert.NoError(t, db.SetTransfer(ctx, &models.Transfer{Token: token, From: sender, To: recipient, BlockNumber: 1, BlockHash: block.Hash().Hex()}))
+	assert.NoError(t, db.SetTransfer(ctx, &models.Transfer{Token: token, From: sender, To: sender, BlockNumber: 1, BlockHash: block.Hash().Hex(), LogIndex: 1}))
+
+	transfers, err := db.GetTransfersByAddress(ctx, sender)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 2, "a transfer to self is indexed once")
+	transfers, err = db.GetTransfersByAddress(ctx, recipient)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 1)
+	transfers, err = db.GetTransfersByToken(ctx, token)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 2)
+
+	_, err = db.RollbackBlock(ctx, 1)
+	assert.NoError(t, err)
+	transfers, err = db.GetTransfersByAddress(ctx, recipient)
+	assert.NoError(t, err)
+	assert.True(t, transfers[0].Removed)
+	transfers, err = db.GetTransfersByToken(ctx, token)
+	assert.NoError(t, err)
+	assert.True(t, transfers[0].Removed)
+	assert.True(t, transfers[1].Removed)
+	assert.Same(t, db.addressTransfers[recipient][0], db.tokenTransfers[token][0], "the indexes share the removed copy")
+
+	for number := int64(2); number <= 3; number++ {
+		assert.NoError(t, db.SetBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})))
+	}
+	transfers, err = db.GetTransfersByAddress(ctx, sender)
+	assert.NoError(t, err)
+	assert.Empty(t, transfers)
+	assert.Empty(t, db.addressTransfers)
+	assert.Empty(t, db.tokenTransfers)
+	assert.Empty(t, db.blockTransferTokens)
+}
+
+func TestAddressTransactionIndex(t *testing.T) {
+	sender := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297").Hex()
+	recipient := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5").Hex()
+	contract := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7").Hex()
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 2}}
+	db := NewInmemortDBService(conf, log.New(os.Stdout, "app", log.LstdFlags)).(*inmemoryDB)
+	ctx := context.Background()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	assert.NoError(t, db.SetBlock(ctx, block))
+	assert.NoError(t, db.SetAddressTransaction(ctx, &models.AddressTransaction{From: sender, To: recipient, BlockNumber: 1}))
+	assert.NoError(t, db.SetAddressTransaction(ctx, &models.AddressTransaction{From: sender, To: sender, BlockNumber: 1, TransactionIndex: 1}))
+	assert.NoError(t, db.SetAddressTransaction(ctx, &models.AddressTransaction{From: sender, ContractAddress: contract, BlockNumber: 1, TransactionIndex: 2}))
+
+	transactions, err := db.GetTransactionsByAddress(ctx, sender)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 3, "a transaction to self is indexed once")
+	transactions, err = db.GetTransactionsByAddress(ctx, contract)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1, "a contract creation is indexed by the created contract")
+
+	_, err = db.RollbackBlock(ctx, 1)
+	assert.NoError(t, err)
+	transactions, err = db.GetTransactionsByAddress(ctx, recipient)
+	assert.NoError(t, err)
+	assert.Empty(t, transactions, "the transactions of a rolled back block are not part of the chain anymore")
+
+	assert.NoError(t, db.SetBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})))
+	assert.NoError(t, db.SetAddressTransaction(ctx, &models.AddressTransaction{From: sender, To: recipient, BlockNumber: 2}))
+	assert.NoError(t, db.SetBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(4)})))
+	assert.Empty(t, db.addressTransactions)
+	assert.Empty(t, db.blockTransactionAddresses)
+}
+
+func TestParticipantIndex(t *testing.T) {
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	wallet := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	conf := config.Config{EthClientConf: config.EthClientConf{NumberOfRecentBlocks: 2}}
+	db := NewInmemortDBService(conf, log.New(os.Stdout, "app", log.LstdFlags)).(*inmemoryDB)
+	ctx := context.Background()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	transferLog := &types.Log{Address: token, BlockNumber: 1, BlockHash: block.Hash(), Topics: []common.Hash{
+		common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+		common.BytesToHash(wallet.Bytes()),
+		common.BigToHash(big.NewInt(7)), // a token id is not an address
+	}}
+	selfLog := &types.Log{Address: wallet, BlockNumber: 1, BlockHash: block.Hash(), Index: 1, Topics: []common.Hash{{}, common.BytesToHash(wallet.Bytes())}}
+	assert.NoError(t, db.SetBlock(ctx, block))
+	assert.NoError(t, db.SetLogByAddress(ctx, token.Hex(), transferLog))
+	assert.NoError(t, db.SetLogByAddress(ctx, wallet.Hex(), selfLog))
+	assert.Len(t, db.participantLogs, 1)
+
+	type testCase struct {
+		role models.AddressRole
+		logs int
+	}
+	for _, tt := range []testCase{{models.AddressRoleEmitter, 1}, {models.AddressRoleParticipant, 2}, {models.AddressRoleAny, 2}} {
+		logs, err := db.GetLogsByAddressInRange(ctx, wallet.Hex(), tt.role, 0, 10)
+		assert.NoError(t, err)
+		assert.Len(t, logs, tt.logs, "role %s", tt.role)
+	}
+
+	_, err := db.RollbackBlock(ctx, 1)
+	assert.NoError(t, err)
+	logs, err := db.GetLogsByAddressInRange(ctx, wallet.Hex(), models.AddressRoleParticipant, 0, 10)
+	assert.NoError(t, err)
+	for _, txLog := range logs {
+		assert.True(t, txLog.Removed)
+	}
+
+	for number := int64(2); number <= 3; number++ {
+		assert.NoError(t, db.SetBlock(ctx, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})))
+	}
+	assert.Empty(t, db.participantLogs)
+	assert.Empty(t, db.blockParticipants)
+}
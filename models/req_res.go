@@ -1,6 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -12,14 +17,385 @@ type ErrorResponse struct {
 
 // EventResponse represents the successful response containing events
 type EventResponse struct {
-	Status  Status      `json:"status"`
-	Address string      `json:"address"`
-	Events  []types.Log `json:"events"`
+	Status  Status  `json:"status"`
+	Address string  `json:"address"`
+	Events  []Event `json:"events"`
+	Next    string  `json:"next,omitempty"` // cursor of the next page, absent on the last page
+}
+
+// Event represents a log, decoded when the ABI of its contract is known
+type Event struct {
+	types.Log
+	EventName   string                 `json:"eventName,omitempty"`
+	DecodedArgs map[string]interface{} `json:"decodedArgs,omitempty"`
+}
+
+// NewEvents wraps the logs as events, not decoded yet
+func NewEvents(logs []types.Log) []Event {
+	events := make([]Event, len(logs))
+	for i := range logs {
+		events[i] = Event{Log: logs[i]}
+	}
+
+	return events
+}
+
+// eventLog is the json form of a log, the same as the encoding of go-ethereum
+type eventLog struct {
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Index       hexutil.Uint   `json:"logIndex"`
+	Removed     bool           `json:"removed"`
+}
+
+// MarshalJSON adds the decoded fields to the json of the log. The embedded log has its own marshaller, which would
+// otherwise be promoted and drop them
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		eventLog
+		EventName   string                 `json:"eventName,omitempty"`
+		DecodedArgs map[string]interface{} `json:"decodedArgs,omitempty"`
+	}{
+		eventLog: eventLog{
+			Address:     e.Address,
+			Topics:      e.Topics,
+			Data:        e.Data,
+			BlockNumber: hexutil.Uint64(e.BlockNumber),
+			TxHash:      e.TxHash,
+			TxIndex:     hexutil.Uint(e.TxIndex),
+			BlockHash:   e.BlockHash,
+			Index:       hexutil.Uint(e.Index),
+			Removed:     e.Removed,
+		},
+		EventName:   e.EventName,
+		DecodedArgs: e.DecodedArgs,
+	})
+}
+
+// SyncStatusResponse represents the successful response containing the state of the ingestion
+type SyncStatusResponse struct {
+	Status Status     `json:"status"`
+	Sync   SyncStatus `json:"sync"`
+}
+
+// SyncStatus represents the state of the ingestion of the blockchain data, visible to the operators
+type SyncStatus struct {
+	Mode           string           `json:"mode"`
+	Connection     ConnectionState  `json:"connection"`
+	LastError      string           `json:"lastError,omitempty"`
+	Reconnects     uint64           `json:"reconnects"`
+	LastSeenBlock  uint64           `json:"lastSeenBlock"`
+	Checkpoint     *uint64          `json:"checkpoint,omitempty"`
+	PendingRetries int              `json:"pendingRetries"`
+	DeadLetters    int              `json:"deadLetters"`
+	Endpoints      []EndpointHealth `json:"endpoints"`
+	RateLimit      RateLimitStatus  `json:"rateLimit"`
+}
+
+// RateLimitStatus represents the usage of the request budget of the outbound requests to the ethereum node
+type RateLimitStatus struct {
+	RequestsPerSecond  float64 `json:"requestsPerSecond"`
+	Burst              float64 `json:"burst"`
+	AvailableTokens    float64 `json:"availableTokens"`
+	Granted            uint64  `json:"granted"`
+	Throttled          uint64  `json:"throttled"`
+	RateLimitedReplies uint64  `json:"rateLimitedReplies"`
+}
+
+// EndpointHealth represents the health statistics of an http endpoint of the ethereum node
+type EndpointHealth struct {
+	URL         string     `json:"url"`
+	Healthy     bool       `json:"healthy"`
+	Requests    uint64     `json:"requests"`
+	Failures    uint64     `json:"failures"`
+	ErrorRate   float64    `json:"errorRate"`
+	LatencyMs   int64      `json:"latencyMs"`
+	PausedUntil *time.Time `json:"pausedUntil,omitempty"` // set while the endpoint is paused by a rate limit reply
+}
+
+type ConnectionState string
+
+const (
+	ConnectionStateConnecting   ConnectionState = "connecting"
+	ConnectionStateConnected    ConnectionState = "connected"
+	ConnectionStateReconnecting ConnectionState = "reconnecting"
+	ConnectionStatePolling      ConnectionState = "polling"
+)
+
+// DeadLettersResponse represents the successful response containing the dead letters
+type DeadLettersResponse struct {
+	Status      Status       `json:"status"`
+	DeadLetters []DeadLetter `json:"deadLetters"`
+}
+
+// DeadLetter represents a block which could not be fetched, or whose transactions' receipts could not be retrieved, after all the
+// retries, so its events are missing. A block which could not be fetched has no hash and no transactions
+type DeadLetter struct {
+	BlockNumber uint64    `json:"blockNumber"`
+	BlockHash   string    `json:"blockHash,omitempty"`
+	TxHashes    []string  `json:"txHashes"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError"`
+	FailedAt    time.Time `json:"failedAt"`
+}
+
+// BlockResponse represents the successful response containing a block
+type BlockResponse struct {
+	Status Status `json:"status"`
+	Block  Block  `json:"block"`
+}
+
+// Block represents the summary of an indexed block
+type Block struct {
+	Number            uint64   `json:"number"`
+	Hash              string   `json:"hash"`
+	ParentHash        string   `json:"parentHash"`
+	Timestamp         uint64   `json:"timestamp"`
+	Miner             string   `json:"miner"`
+	GasLimit          uint64   `json:"gasLimit"`
+	GasUsed           uint64   `json:"gasUsed"`
+	BaseFeePerGas     string   `json:"baseFeePerGas,omitempty"` // in wei, absent before the london fork
+	TransactionHashes []string `json:"transactionHashes"`
+	LogCount          int      `json:"logCount"`
+}
+
+// BlockTransactionsResponse represents the successful response containing a page of the transaction hashes of a block
+type BlockTransactionsResponse struct {
+	Status            Status   `json:"status"`
+	BlockNumber       uint64   `json:"blockNumber"`
+	BlockHash         string   `json:"blockHash"`
+	TransactionHashes []string `json:"transactionHashes"`
+	Next              string   `json:"next,omitempty"` // cursor of the next page, absent on the last page
+}
+
+// TransactionResponse represents the successful response containing a transaction
+type TransactionResponse struct {
+	Status      Status      `json:"status"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// Transaction represents an indexed transaction with its receipt and logs. The amounts are in wei
+type Transaction struct {
+	Hash                 string   `json:"hash"`
+	BlockNumber          uint64   `json:"blockNumber"`
+	BlockHash            string   `json:"blockHash"`
+	TransactionIndex     int      `json:"transactionIndex"`
+	From                 string   `json:"from"`
+	To                   string   `json:"to,omitempty"` // absent for contract creations
+	Value                string   `json:"value"`
+	Nonce                uint64   `json:"nonce"`
+	Type                 uint8    `json:"type"`
+	Gas                  uint64   `json:"gas"`
+	GasPrice             string   `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string   `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string   `json:"maxPriorityFeePerGas,omitempty"`
+	Receipt              *Receipt `json:"receipt,omitempty"` // absent while its retrieval is being retried
+	Logs                 []Event  `json:"logs"`
+}
+
+// Receipt represents the outcome of a transaction
+type Receipt struct {
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gasUsed"`
+	CumulativeGasUsed uint64 `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	ContractAddress   string `json:"contractAddress,omitempty"`
+}
+
+// AddressTransactionsResponse represents the successful response containing a page of the transactions of an address
+type AddressTransactionsResponse struct {
+	Status       Status               `json:"status"`
+	Address      string               `json:"address"`
+	Transactions []AddressTransaction `json:"transactions"`
+	Next         string               `json:"next,omitempty"` // cursor of the next page, absent on the last page
+}
+
+// AddressTransaction represents a transaction sent or received by an address. A contract creation is received by the
+// created contract. The addresses are checksummed and the value is in wei
+type AddressTransaction struct {
+	Hash             string `json:"hash"`
+	BlockNumber      uint64 `json:"blockNumber"`
+	BlockHash        string `json:"blockHash"`
+	TransactionIndex int    `json:"transactionIndex"`
+	From             string `json:"from"`
+	To               string `json:"to,omitempty"`              // absent for contract creations
+	ContractAddress  string `json:"contractAddress,omitempty"` // contract creations only
+	Value            string `json:"value"`
+	Status           uint64 `json:"status"` // status of the receipt, 1 for success and 0 for failure
+}
+
+// RPCRequest represents a json-rpc 2.0 request of the json-rpc facade
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// RPCResponse represents a json-rpc 2.0 response, carrying either the result or the error of the request. A null result is kept
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError represents a json-rpc 2.0 error. The codes are the json-rpc ones, like -32602 for invalid params
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RPCNotification represents a json-rpc 2.0 notification of a subscription, pushed over the websocket
+type RPCNotification struct {
+	JSONRPC string                `json:"jsonrpc"`
+	Method  string                `json:"method"`
+	Params  RPCSubscriptionResult `json:"params"`
+}
+
+// RPCSubscriptionResult represents an item of a subscription, a log or a block header, with the id of the subscription
+type RPCSubscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// ABIResponse represents the successful response of a contract ABI
+type ABIResponse struct {
+	Status  Status          `json:"status"`
+	Address string          `json:"address"`
+	ABI     json.RawMessage `json:"abi,omitempty"`
+}
+
+// TransfersResponse represents the successful response containing a page of the token transfers of an address or of a token
+type TransfersResponse struct {
+	Status    Status     `json:"status"`
+	Address   string     `json:"address"`
+	Transfers []Transfer `json:"transfers"`
+	Next      string     `json:"next,omitempty"` // cursor of the next page, absent on the last page
+}
+
+// Transfer represents a token transfer of a standard token contract. The addresses are checksummed
+type Transfer struct {
+	Standard    TokenStandard `json:"standard"`
+	Token       string        `json:"token"`
+	Operator    string        `json:"operator,omitempty"` // erc-1155 only
+	From        string        `json:"from"`
+	To          string        `json:"to"`
+	TokenID     string        `json:"tokenId,omitempty"` // erc-721 and erc-1155 only
+	Value       string        `json:"value,omitempty"`   // erc-20 and erc-1155 only
+	BlockNumber uint64        `json:"blockNumber"`
+	BlockHash   string        `json:"blockHash"`
+	TxHash      string        `json:"transactionHash"`
+	LogIndex    uint          `json:"logIndex"`
+	BatchIndex  int           `json:"batchIndex"` // position in an erc-1155 batch transfer, zero otherwise
+	Removed     bool          `json:"removed"`    // the same as the removed field of the logs, after a chain reorganization
+}
+
+type TokenStandard string
+
+const (
+	TokenStandardERC20   TokenStandard = "erc20"
+	TokenStandardERC721  TokenStandard = "erc721"
+	TokenStandardERC1155 TokenStandard = "erc1155"
+)
+
+// AddressRole selects the logs of an address by its role in them
+type AddressRole string
+
+const (
+	AddressRoleEmitter     AddressRole = "emitter"     // the contract emitting the log
+	AddressRoleParticipant AddressRole = "participant" // an address appearing in the indexed topics of the log
+	AddressRoleAny         AddressRole = "any"
+)
+
+// TransferDirection selects the transfers or the transactions of an address by its side
+type TransferDirection string
+
+const (
+	TransferDirectionIn  TransferDirection = "in"
+	TransferDirectionOut TransferDirection = "out"
+	TransferDirectionAny TransferDirection = "any"
+)
+
+// BlockTag is a block named by its state in the chain instead of its number, like the block tags of the json-rpc api
+type BlockTag string
+
+const (
+	BlockTagLatest    BlockTag = "latest"
+	BlockTagSafe      BlockTag = "safe"
+	BlockTagFinalized BlockTag = "finalized"
+)
+
+// WebhookRequest represents the registration of a webhook. The topics have the semantics of the topics of eth_getLogs
+type WebhookRequest struct {
+	URL       string     `json:"url"`
+	Addresses []string   `json:"addresses"`
+	Topics    [][]string `json:"topics"`
+	Secret    string     `json:"secret"` // optional, signs the deliveries by hmac-sha256
+}
+
+// Webhook represents a registered webhook, notified of the logs emitted by its addresses and matching its topics. The secret is
+// never returned
+type Webhook struct {
+	ID                  string           `json:"id"`
+	URL                 string           `json:"url"`
+	Addresses           []common.Address `json:"addresses"`
+	Topics              [][]common.Hash  `json:"topics"`
+	Secret              string           `json:"-"`
+	Signed              bool             `json:"signed"`
+	Enabled             bool             `json:"enabled"`
+	ConsecutiveFailures int              `json:"consecutiveFailures"`
+	DisabledReason      string           `json:"disabledReason,omitempty"`
+	DeliveredBlock      uint64           `json:"deliveredBlock"` // every matching log of the blocks up to it is acknowledged, the delivery resumes after it
+	CreatedAt           time.Time        `json:"createdAt"`
+}
+
+// WebhookDelivery represents an attempt to deliver a batch of events to a webhook. The retries of a batch share its id
+type WebhookDelivery struct {
+	ID          string    `json:"id"`
+	Attempt     int       `json:"attempt"`
+	Events      int       `json:"events"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// WebhookPayload represents the body posted to a webhook
+type WebhookPayload struct {
+	WebhookID  string  `json:"webhookId"`
+	DeliveryID string  `json:"deliveryId"`
+	Events     []Event `json:"events"`
+}
+
+// WebhookResponse represents the successful response containing a webhook
+type WebhookResponse struct {
+	Status  Status  `json:"status"`
+	Webhook Webhook `json:"webhook"`
+}
+
+// WebhooksResponse represents the successful response containing the registered webhooks
+type WebhooksResponse struct {
+	Status   Status    `json:"status"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// WebhookDeliveriesResponse represents the successful response containing the latest deliveries of a webhook, the newest first
+type WebhookDeliveriesResponse struct {
+	Status     Status            `json:"status"`
+	WebhookID  string            `json:"webhookId"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
 }
 
 type Status string
 
 const (
-	StatusSuccess Status = "success"
-	StatusCreated Status = "created"
+	StatusSuccess  Status = "success"
+	StatusCreated  Status = "created"
+	StatusAccepted Status = "accepted"
 )
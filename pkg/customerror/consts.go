@@ -11,6 +11,8 @@ const (
 	ErrCodeDatabase                             // 6003
 	ErrCodeNetwork                              // 6004
 	ErrCodeInternal                             // 6005
+	ErrCodeOutOfRange                           // 6006
+	ErrCodeUnauthorized                         // 6007
 )
 
 var (
@@ -20,6 +22,8 @@ var (
 	ErrDatabase     = errors.New("error database")
 	ErrNetwork      = errors.New("error network")
 	ErrInternal     = errors.New("error internal")
+	ErrOutOfRange   = errors.New("error out of the indexed range")
+	ErrUnauthorized = errors.New("error unauthorized")
 )
 
 const (
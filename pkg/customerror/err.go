@@ -83,3 +83,47 @@ func NewStorageError(message string, err error) *Error {
 
 	return New(ErrCodeStorage, message, err)
 }
+
+func NewNotFoundError(message string, err error) *Error {
+	if message == "" && err != nil {
+		return New(ErrCodeNotFound, ErrNotFound.Error(), err)
+	}
+	if err == nil && message != "" {
+		return New(ErrCodeNotFound, message, ErrNotFound)
+	}
+
+	return New(ErrCodeNotFound, message, err)
+}
+
+func NewInvalidInputError(message string, err error) *Error {
+	if message == "" && err != nil {
+		return New(ErrCodeInvalidInput, ErrInvalidInput.Error(), err)
+	}
+	if err == nil && message != "" {
+		return New(ErrCodeInvalidInput, message, ErrInvalidInput)
+	}
+
+	return New(ErrCodeInvalidInput, message, err)
+}
+
+func NewOutOfRangeError(message string, err error) *Error {
+	if message == "" && err != nil {
+		return New(ErrCodeOutOfRange, ErrOutOfRange.Error(), err)
+	}
+	if err == nil && message != "" {
+		return New(ErrCodeOutOfRange, message, ErrOutOfRange)
+	}
+
+	return New(ErrCodeOutOfRange, message, err)
+}
+
+func NewUnauthorizedError(message string, err error) *Error {
+	if message == "" && err != nil {
+		return New(ErrCodeUnauthorized, ErrUnauthorized.Error(), err)
+	}
+	if err == nil && message != "" {
+		return New(ErrCodeUnauthorized, message, ErrUnauthorized)
+	}
+
+	return New(ErrCodeUnauthorized, message, err)
+}
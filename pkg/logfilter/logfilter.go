@@ -0,0 +1,39 @@
+// Package logfilter matches the logs against the filter queries of eth_getLogs, the same as go-ethereum
+package logfilter
+
+import (
+	"slices"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Match reports whether a log matches a filter query, the same as go-ethereum: the non-negative block bounds include the log, the
+// addresses include its emitter, the log has at least as many topics as the query, and each position of the query is either empty or
+// includes the topic of the log. The values of a position are OR-ed and the positions are AND-ed. The negative bounds are the block
+// tags, which include any log
+func Match(query ethereum.FilterQuery, txLog types.Log) bool {
+	if query.FromBlock != nil && query.FromBlock.Sign() >= 0 && query.FromBlock.Uint64() > txLog.BlockNumber {
+		return false
+	}
+	if query.ToBlock != nil && query.ToBlock.Sign() >= 0 && query.ToBlock.Uint64() < txLog.BlockNumber {
+		return false
+	}
+	if query.BlockHash != nil && *query.BlockHash != txLog.BlockHash {
+		return false
+	}
+	if len(query.Addresses) != 0 && !slices.Contains(query.Addresses, txLog.Address) {
+		return false
+	}
+	if len(query.Topics) > len(txLog.Topics) {
+		return false
+	}
+
+	for i, sub := range query.Topics {
+		if len(sub) != 0 && !slices.Contains(sub, txLog.Topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
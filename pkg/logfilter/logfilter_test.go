@@ -0,0 +1,49 @@
+package logfilter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	blockHash := common.HexToHash("0x01")
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	approval := common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+	sender := common.HexToHash("0x000000000000000000000000388c818ca8b9251b393131c08a736a67ccb19297")
+	txLog := types.Log{Address: token, BlockNumber: 5, BlockHash: blockHash, Topics: []common.Hash{transfer, sender}}
+	otherHash := common.HexToHash("0x02")
+
+	type testCase struct {
+		name    string
+		query   ethereum.FilterQuery
+		matched bool
+	}
+	for _, tt := range []testCase{
+		{name: "open bounds", matched: true},
+		{name: "in the bounds", query: ethereum.FilterQuery{FromBlock: big.NewInt(5), ToBlock: big.NewInt(5)}, matched: true},
+		{name: "before the range", query: ethereum.FilterQuery{FromBlock: big.NewInt(6)}},
+		{name: "after the range", query: ethereum.FilterQuery{ToBlock: big.NewInt(4)}},
+		{name: "latest bound", query: ethereum.FilterQuery{FromBlock: big.NewInt(int64(rpc.LatestBlockNumber))}, matched: true},
+		{name: "same block hash", query: ethereum.FilterQuery{BlockHash: &blockHash}, matched: true},
+		{name: "other block hash", query: ethereum.FilterQuery{BlockHash: &otherHash}},
+		{name: "emitter address", query: ethereum.FilterQuery{Addresses: []common.Address{{}, token}}, matched: true},
+		{name: "other address", query: ethereum.FilterQuery{Addresses: []common.Address{{}}}},
+		{name: "matching first topic", query: ethereum.FilterQuery{Topics: [][]common.Hash{{transfer}}}, matched: true},
+		{name: "OR-ed values of a position", query: ethereum.FilterQuery{Topics: [][]common.Hash{{approval, transfer}}}, matched: true},
+		{name: "not matching first topic", query: ethereum.FilterQuery{Topics: [][]common.Hash{{approval}}}},
+		{name: "wildcard first position", query: ethereum.FilterQuery{Topics: [][]common.Hash{{}, {sender}}}, matched: true},
+		{name: "AND-ed positions", query: ethereum.FilterQuery{Topics: [][]common.Hash{{transfer}, {transfer}}}},
+		{name: "position beyond the topics of the log", query: ethereum.FilterQuery{Topics: [][]common.Hash{{}, {}, {sender}}}},
+		{name: "wildcard position beyond the topics of the log", query: ethereum.FilterQuery{Topics: [][]common.Hash{{transfer}, {}, {}}}},
+		{name: "wildcard positions of all the topics of the log", query: ethereum.FilterQuery{Topics: [][]common.Hash{{}, {}}}, matched: true},
+	} {
+		assert.Equal(t, tt.matched, Match(tt.query, txLog), tt.name)
+	}
+}
@@ -0,0 +1,102 @@
+// Package tokentransfer recognizes the transfers of the standard token contracts, erc-20, erc-721 and erc-1155, from their logs
+package tokentransfer
+
+import (
+	"ethereum-tracker-app/models"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// Transfer(address indexed from, address indexed to, uint256 value) of erc-20, the same signature with an indexed token id in erc-721
+	TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	// TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id, uint256 value) of erc-1155
+	TransferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	// TransferBatch(address indexed operator, address indexed from, address indexed to, uint256[] ids, uint256[] values) of erc-1155
+	TransferBatchTopic = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+
+	batchArguments abi.Arguments
+)
+
+func init() {
+	uint256Array, err := abi.NewType("uint256[]", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	batchArguments = abi.Arguments{{Name: "ids", Type: uint256Array}, {Name: "values", Type: uint256Array}}
+}
+
+// Parse gets the token transfers of a log, none if it is not a standard transfer event. The number of the indexed topics
+// tells erc-20 and erc-721 apart, as they share the signature
+func Parse(txLog types.Log) []models.Transfer {
+	if len(txLog.Topics) == 0 {
+		return nil
+	}
+
+	switch txLog.Topics[0] {
+	case TransferTopic:
+		switch {
+		case len(txLog.Topics) == 3 && len(txLog.Data) == common.HashLength:
+			transfer := newTransfer(txLog, models.TokenStandardERC20, txLog.Topics[1], txLog.Topics[2])
+			transfer.Value = new(big.Int).SetBytes(txLog.Data).String()
+			return []models.Transfer{transfer}
+		case len(txLog.Topics) == 4 && len(txLog.Data) == 0:
+			transfer := newTransfer(txLog, models.TokenStandardERC721, txLog.Topics[1], txLog.Topics[2])
+			transfer.TokenID = txLog.Topics[3].Big().String()
+			return []models.Transfer{transfer}
+		}
+	case TransferSingleTopic:
+		if len(txLog.Topics) != 4 || len(txLog.Data) != 2*common.HashLength {
+			return nil
+		}
+
+		transfer := newTransfer(txLog, models.TokenStandardERC1155, txLog.Topics[2], txLog.Topics[3])
+		transfer.Operator = common.BytesToAddress(txLog.Topics[1].Bytes()).Hex()
+		transfer.TokenID = new(big.Int).SetBytes(txLog.Data[:common.HashLength]).String()
+		transfer.Value = new(big.Int).SetBytes(txLog.Data[common.HashLength:]).String()
+		return []models.Transfer{transfer}
+	case TransferBatchTopic:
+		if len(txLog.Topics) != 4 {
+			return nil
+		}
+
+		values, err := batchArguments.Unpack(txLog.Data)
+		if err != nil {
+			return nil
+		}
+		ids, amounts := values[0].([]*big.Int), values[1].([]*big.Int)
+		if len(ids) != len(amounts) {
+			return nil
+		}
+
+		transfers := make([]models.Transfer, len(ids))
+		for i := range ids {
+			transfers[i] = newTransfer(txLog, models.TokenStandardERC1155, txLog.Topics[2], txLog.Topics[3])
+			transfers[i].Operator = common.BytesToAddress(txLog.Topics[1].Bytes()).Hex()
+			transfers[i].TokenID = ids[i].String()
+			transfers[i].Value = amounts[i].String()
+			transfers[i].BatchIndex = i
+		}
+		return transfers
+	}
+
+	return nil
+}
+
+func newTransfer(txLog types.Log, standard models.TokenStandard, from, to common.Hash) models.Transfer {
+	return models.Transfer{
+		Standard:    standard,
+		Token:       txLog.Address.Hex(),
+		From:        common.BytesToAddress(from.Bytes()).Hex(),
+		To:          common.BytesToAddress(to.Bytes()).Hex(),
+		BlockNumber: txLog.BlockNumber,
+		BlockHash:   txLog.BlockHash.Hex(),
+		TxHash:      txLog.TxHash.Hex(),
+		LogIndex:    txLog.Index,
+		Removed:     txLog.Removed,
+	}
+}
@@ -0,0 +1,81 @@
+package tokentransfer
+
+import (
+	"ethereum-tracker-app/models"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	token := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	operator := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	from := common.HexToAddress("0x388C818CA8B9251b393131C08a736A67ccB19297")
+	to := common.HexToAddress("0x95222290DD7278Aa3Ddd389Cc1E1d165CC4BAfe5")
+	addressTopic := func(address common.Address) common.Hash { return common.BytesToHash(address.Bytes()) }
+	word := func(value int64) []byte { return common.BigToHash(big.NewInt(value)).Bytes() }
+
+	batchData, err := batchArguments.Pack([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name      string
+		txLog     types.Log
+		transfers []models.Transfer
+	}
+
+	base := models.Transfer{Token: token.Hex(), From: from.Hex(), To: to.Hex(), BlockHash: common.Hash{}.Hex(), TxHash: common.Hash{}.Hex()}
+	withFields := func(standard models.TokenStandard, tokenID, value string, batchIndex int) models.Transfer {
+		transfer := base
+		transfer.Standard, transfer.TokenID, transfer.Value, transfer.BatchIndex = standard, tokenID, value, batchIndex
+		if standard == models.TokenStandardERC1155 {
+			transfer.Operator = operator.Hex()
+		}
+		return transfer
+	}
+
+	testcases := []testCase{
+		{
+			name:      "erc-20 transfer",
+			txLog:     types.Log{Address: token, Topics: []common.Hash{TransferTopic, addressTopic(from), addressTopic(to)}, Data: word(500)},
+			transfers: []models.Transfer{withFields(models.TokenStandardERC20, "", "500", 0)},
+		},
+		{
+			name:      "erc-721 transfer",
+			txLog:     types.Log{Address: token, Topics: []common.Hash{TransferTopic, addressTopic(from), addressTopic(to), common.BigToHash(big.NewInt(7))}},
+			transfers: []models.Transfer{withFields(models.TokenStandardERC721, "7", "", 0)},
+		},
+		{
+			name: "erc-1155 single transfer",
+			txLog: types.Log{Address: token, Topics: []common.Hash{TransferSingleTopic, addressTopic(operator), addressTopic(from), addressTopic(to)},
+				Data: append(word(3), word(4)...)},
+			transfers: []models.Transfer{withFields(models.TokenStandardERC1155, "3", "4", 0)},
+		},
+		{
+			name:  "erc-1155 batch transfer",
+			txLog: types.Log{Address: token, Topics: []common.Hash{TransferBatchTopic, addressTopic(operator), addressTopic(from), addressTopic(to)}, Data: batchData},
+			transfers: []models.Transfer{
+				withFields(models.TokenStandardERC1155, "1", "10", 0),
+				withFields(models.TokenStandardERC1155, "2", "20", 1),
+			},
+		},
+		{
+			name:  "transfer with non-standard indexing",
+			txLog: types.Log{Address: token, Topics: []common.Hash{TransferTopic}, Data: append(word(1), word(2)...)},
+		},
+		{
+			name:  "other event",
+			txLog: types.Log{Address: token, Topics: []common.Hash{common.HexToHash("0x01")}},
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transfers, Parse(tt.txLog))
+		})
+	}
+}
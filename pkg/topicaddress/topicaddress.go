@@ -0,0 +1,33 @@
+// Package topicaddress recognizes the addresses referred to by the indexed topics of the logs
+package topicaddress
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Parse gets the checksummed addresses appearing in the indexed topics of a log, as 32 bytes values left-padded with zeros.
+// The values below 2^96 are taken as numbers rather than addresses, e.g. token ids, to keep the index free of false positives
+func Parse(log *types.Log) []string {
+	if len(log.Topics) <= 1 {
+		return nil
+	}
+
+	addresses := make([]string, 0, len(log.Topics)-1)
+	for _, topic := range log.Topics[1:] { // the first topic is the signature of the event
+		padding, address := topic[:common.HashLength-common.AddressLength], topic[common.HashLength-common.AddressLength:]
+		if !bytes.Equal(padding, make([]byte, len(padding))) || bytes.Equal(address[:8], make([]byte, 8)) {
+			continue
+		}
+
+		addressHex := common.BytesToAddress(address).Hex()
+		if !slices.Contains(addresses, addressHex) {
+			addresses = append(addresses, addressHex)
+		}
+	}
+
+	return addresses
+}